@@ -10,11 +10,19 @@ import (
 
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/config"
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/metrics"
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/notify"
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/server"
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		backfill(os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -23,16 +31,83 @@ func main() {
 
 	log.Printf("Starting SonarQube Prometheus Exporter")
 	log.Printf("SonarQube URL: %s", cfg.SonarQubeURL)
-	log.Printf("Server address: %s", cfg.Address())
+	if !cfg.Once {
+		log.Printf("Server address: %s", cfg.Address())
+	}
+	if cfg.SonarQubeProxyURL != nil {
+		log.Printf("SonarQube proxy: %s", cfg.SonarQubeProxyURL.Redacted())
+	}
 
 	// Create SonarQube client
-	sqClient := sonarqube.NewClient(cfg.SonarQubeURL, cfg.SonarQubeToken)
+	sqClient := newSonarQubeClient(cfg)
+
+	// Check which authentication mode SonarQube accepts
+	if mode, err := sqClient.ProbeAuth(); err != nil {
+		log.Printf("Warning: %v", err)
+	} else {
+		log.Printf("Authenticated to SonarQube using the %s mode", mode)
+	}
+
+	// Reload the token when its file changes, e.g. on a Kubernetes secret rotation
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if cfg.SonarQubeTokenFile != "" {
+		go sqClient.WatchTokenFile(watchCtx, cfg.SonarQubeTokenFile, cfg.SonarQubeTokenFileInterval)
+	}
+
+	// Create the notifier of quality gate transitions
+	var notifier *notify.Notifier
+	if len(cfg.NotifyURLs) > 0 {
+		var notifyOpts []notify.Option
+		if cfg.NotifyTemplateFile != "" {
+			tmpl, err := notify.LoadTemplate(cfg.NotifyTemplateFile)
+			if err != nil {
+				log.Fatalf("Failed to load notification template: %v", err)
+			}
+			notifyOpts = append(notifyOpts, notify.WithTemplate(tmpl))
+		}
+		notifier = notify.New(cfg.NotifyURLs, notifyOpts...)
+	}
 
 	// Create Prometheus collector
-	collector := metrics.NewCollector(sqClient)
+	collector := metrics.NewCollector(sqClient,
+		metrics.WithMetricCatalogTTL(cfg.MetricCatalogTTL),
+		metrics.WithIncrementalRefresh(cfg.IncrementalRefresh, cfg.FullResyncInterval),
+		metrics.WithSnapshot(cfg.SnapshotFile),
+		metrics.WithAnalysisTimestamps(cfg.AnalysisTimestamps, cfg.TimestampWindow),
+		metrics.WithAnalysisWebhooks(cfg.Webhook),
+		metrics.WithQualityGateNotifications(notifier),
+		metrics.WithRatingLabels(cfg.RatingLabels),
+		metrics.WithStaleThreshold(cfg.StaleThreshold),
+		metrics.WithTagLabels(cfg.TagLabelPrefixes),
+		metrics.WithAnalysisHistory(cfg.AnalysisHistory),
+		metrics.WithHotspots(cfg.Hotspots),
+		metrics.WithSecurityReports(cfg.SecurityStandards),
+		metrics.WithQualityProfiles(cfg.QualityProfiles, cfg.StandardProfiles),
+		metrics.WithLicense(cfg.License),
+		metrics.WithTokenMonitoring(cfg.TokenMonitoring, cfg.SonarQubeTokenName),
+	)
+
+	// In one-shot mode, write a single collection for the textfile collector of node_exporter
+	if cfg.Once {
+		writeOnce(collector, cfg.Output)
+		return
+	}
+
+	// Serve the last snapshot until the first refresh completes
+	if err := collector.LoadSnapshot(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
 	// Create HTTP server
-	srv := server.New(cfg.Address(), collector)
+	var serverOpts []server.Option
+	if cfg.Webhook {
+		serverOpts = append(serverOpts, server.WithWebhook(cfg.WebhookSecret, collector))
+		if !cfg.IncrementalRefresh {
+			log.Printf("Warning: webhooks only count analyses without incremental-refresh")
+		}
+	}
+	srv := server.New(cfg.Address(), collector, serverOpts...)
 
 	// Start server in a goroutine
 	go func() {
@@ -44,6 +119,18 @@ func main() {
 	log.Printf("Server started successfully on %s", cfg.Address())
 	log.Printf("Metrics available at http://%s/metrics", cfg.Address())
 
+	// Push the metrics to the Pushgateway, if any
+	pushCtx, stopPushing := context.WithCancel(context.Background())
+	pushDone := make(chan struct{})
+	if cfg.PushURL != "" {
+		go func() {
+			server.NewPusher(srv.Registry(), cfg.PushOptions()).Run(pushCtx)
+			close(pushDone)
+		}()
+	} else {
+		close(pushDone)
+	}
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -53,9 +140,93 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	stopPushing()
+	<-pushDone
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Send the quality gate notifications of the last collections before exiting
+	if err := collector.Close(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	log.Println("Server exited")
 }
+
+// newSonarQubeClient creates the SonarQube client with the connection settings of the configuration
+func newSonarQubeClient(cfg *config.Config) *sonarqube.Client {
+	// Build the TLS configuration of the SonarQube connections
+	tlsConfig, err := sonarqube.NewTLSConfig(cfg.TLSOptions())
+	if err != nil {
+		log.Fatalf("Failed to load SonarQube TLS configuration: %v", err)
+	}
+	if cfg.SonarQubeTLSInsecureSkipVerify {
+		log.Printf("Warning: verification of the SonarQube certificate is disabled")
+	}
+
+	return sonarqube.NewClient(cfg.SonarQubeURL, cfg.SonarQubeToken,
+		sonarqube.WithAdminToken(cfg.SonarQubeAdminToken),
+		sonarqube.WithAuthMode(cfg.SonarQubeAuthMode),
+		sonarqube.WithBasicAuth(cfg.SonarQubeUsername, cfg.SonarQubePassword),
+		sonarqube.WithAuthHeader(cfg.SonarQubeAuthHeader),
+		sonarqube.WithTLSConfig(tlsConfig),
+		sonarqube.WithTransportOptions(cfg.TransportOptions()),
+	)
+}
+
+// writeOnce runs one collection and writes it to a file in the text exposition format. The file is
+// left untouched if the collection fails, so that a stale file shows through its modification time.
+func writeOnce(collector *metrics.Collector, output string) {
+	families, err := server.NewRegistry(collector).Gather()
+	if err != nil {
+		log.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if !collector.Complete() {
+		log.Fatalf("Collection failed, %s was not written", output)
+	}
+
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, nil })
+	if err := prometheus.WriteToTextfile(output, gatherer); err != nil {
+		log.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	log.Printf("Metrics written to %s", output)
+}
+
+// backfill runs the backfill subcommand, writing the measure history of SonarQube to an
+// OpenMetrics file
+func backfill(args []string) {
+	cfg, backfillCfg, err := config.LoadBackfill(args)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	log.Printf("Backfilling the measure history of %s", cfg.SonarQubeURL)
+
+	collector := metrics.NewCollector(newSonarQubeClient(cfg),
+		metrics.WithTagLabels(cfg.TagLabelPrefixes),
+	)
+
+	out := os.Stdout
+	if backfillCfg.Output != "-" {
+		out, err = os.Create(backfillCfg.Output)
+		if err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+	}
+
+	err = collector.Backfill(out, metrics.BackfillOptions{
+		ProjectKeys: backfillCfg.Projects,
+		MetricKeys:  backfillCfg.Metrics,
+		From:        backfillCfg.From,
+		To:          backfillCfg.To,
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+}
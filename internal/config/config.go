@@ -1,9 +1,17 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/server"
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
 )
 
 // Config holds the application configuration
@@ -13,8 +21,82 @@ type Config struct {
 	Port string
 
 	// SonarQube configuration
-	SonarQubeURL   string
-	SonarQubeToken string
+	SonarQubeURL               string
+	SonarQubeToken             string
+	SonarQubeTokenFile         string
+	SonarQubeTokenFileInterval time.Duration
+	SonarQubeAdminToken        string
+	SonarQubeAuthMode          sonarqube.AuthMode
+	SonarQubeUsername          string
+	SonarQubePassword          string
+	SonarQubeAuthHeader        string
+	SonarQubeTokenName         string
+
+	// SonarQube TLS configuration
+	SonarQubeCAFile                string
+	SonarQubeCertFile              string
+	SonarQubeKeyFile               string
+	SonarQubeTLSMinVersion         uint16
+	SonarQubeTLSServerName         string
+	SonarQubeTLSInsecureSkipVerify bool
+
+	// SonarQube connection configuration
+	SonarQubeProxyURL              *url.URL
+	SonarQubeNoProxy               []string
+	SonarQubeMaxIdleConns          int
+	SonarQubeMaxIdleConnsPerHost   int
+	SonarQubeIdleConnTimeout       time.Duration
+	SonarQubeKeepAlive             time.Duration
+	SonarQubeTimeout               time.Duration
+	SonarQubeDialTimeout           time.Duration
+	SonarQubeTLSHandshakeTimeout   time.Duration
+	SonarQubeResponseHeaderTimeout time.Duration
+	SonarQubeHTTP2                 bool
+
+	// Metrics configuration
+	MetricCatalogTTL   time.Duration
+	IncrementalRefresh bool
+	FullResyncInterval time.Duration
+	SnapshotFile       string
+	AnalysisTimestamps bool
+	TimestampWindow    time.Duration
+	Webhook            bool
+	WebhookSecret      string
+	NotifyURLs         []string
+	NotifyTemplateFile string
+	RatingLabels       bool
+	StaleThreshold     time.Duration
+	TagLabelPrefixes   []string
+	AnalysisHistory    bool
+	Hotspots           bool
+	SecurityStandards  []string
+	QualityProfiles    bool
+	StandardProfiles   []string
+	License            bool
+	TokenMonitoring    bool
+
+	// One-shot mode configuration
+	Once   bool
+	Output string
+
+	// Push mode configuration
+	PushURL              string
+	PushJob              string
+	PushInstance         string
+	PushUsername         string
+	PushPassword         string
+	PushInterval         time.Duration
+	PushTimeout          time.Duration
+	PushDeleteOnShutdown bool
+}
+
+// Backfill represents the configuration of the backfill subcommand
+type Backfill struct {
+	Projects []string
+	Metrics  []string
+	From     time.Time
+	To       time.Time
+	Output   string
 }
 
 // Load loads configuration from environment variables and CLI flags
@@ -25,28 +107,224 @@ func Load() (*Config, error) {
 // LoadWithFlagSet loads configuration with a custom flag set (useful for testing)
 func LoadWithFlagSet(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{}
+	env := &envVars{}
+	var tagLabelPrefixes, securityStandards, standardProfiles, authMode, tlsMinVersion, proxyURL, noProxy, notifyURLs string
 
 	// Define CLI flags
 	fs.StringVar(&cfg.Host, "host", getEnv("EXPORTER_HOST", "0.0.0.0"), "Host to bind the exporter server")
 	fs.StringVar(&cfg.Port, "port", getEnv("EXPORTER_PORT", "9090"), "Port to bind the exporter server")
 	fs.StringVar(&cfg.SonarQubeURL, "sonarqube-url", getEnv("SONARQUBE_URL", ""), "SonarQube server URL")
 	fs.StringVar(&cfg.SonarQubeToken, "sonarqube-token", getEnv("SONARQUBE_TOKEN", ""), "SonarQube authentication token")
+	fs.StringVar(&cfg.SonarQubeTokenFile, "sonarqube-token-file", getEnv("SONARQUBE_TOKEN_FILE", ""), "File containing the SonarQube authentication token, reloaded when it changes")
+	fs.DurationVar(&cfg.SonarQubeTokenFileInterval, "sonarqube-token-file-interval", env.getDuration("SONARQUBE_TOKEN_FILE_INTERVAL", 30*time.Second), "Interval between checks of the token file for changes")
+	fs.StringVar(&authMode, "sonarqube-auth-mode", getEnv("SONARQUBE_AUTH_MODE", string(sonarqube.AuthBearer)), "SonarQube authentication mode: bearer, basic-token, basic, header or auto")
+	fs.StringVar(&cfg.SonarQubeUsername, "sonarqube-username", getEnv("SONARQUBE_USERNAME", ""), "SonarQube username, for the basic authentication mode")
+	fs.StringVar(&cfg.SonarQubePassword, "sonarqube-password", getEnv("SONARQUBE_PASSWORD", ""), "SonarQube password, for the basic authentication mode")
+	fs.StringVar(&cfg.SonarQubeAuthHeader, "sonarqube-auth-header", getEnv("SONARQUBE_AUTH_HEADER", ""), "Header carrying the token, for the header authentication mode")
+	fs.StringVar(&cfg.SonarQubeAdminToken, "sonarqube-admin-token", getEnv("SONARQUBE_ADMIN_TOKEN", ""), "SonarQube token with administrator permissions, used for license metrics (defaults to sonarqube-token)")
+	fs.StringVar(&cfg.SonarQubeCAFile, "sonarqube-ca-file", getEnv("SONARQUBE_CA_FILE", ""), "PEM bundle of the certificate authorities trusted for SonarQube, instead of the system ones")
+	fs.StringVar(&cfg.SonarQubeCertFile, "sonarqube-cert-file", getEnv("SONARQUBE_CERT_FILE", ""), "PEM client certificate presented to SonarQube, for mTLS")
+	fs.StringVar(&cfg.SonarQubeKeyFile, "sonarqube-key-file", getEnv("SONARQUBE_KEY_FILE", ""), "PEM key of the client certificate, for mTLS")
+	fs.StringVar(&tlsMinVersion, "sonarqube-tls-min-version", getEnv("SONARQUBE_TLS_MIN_VERSION", ""), "Minimum TLS version of the connections to SonarQube: 1.0, 1.1, 1.2 or 1.3")
+	fs.StringVar(&cfg.SonarQubeTLSServerName, "sonarqube-tls-server-name", getEnv("SONARQUBE_TLS_SERVER_NAME", ""), "Server name used for SNI and verification of the SonarQube certificate, instead of the URL host")
+	fs.BoolVar(&cfg.SonarQubeTLSInsecureSkipVerify, "sonarqube-tls-insecure-skip-verify", env.getBool("SONARQUBE_TLS_INSECURE_SKIP_VERIFY", false), "Disable the verification of the SonarQube certificate (insecure)")
+	fs.StringVar(&proxyURL, "sonarqube-proxy-url", getEnv("SONARQUBE_PROXY_URL", ""), "Proxy used for SonarQube requests, instead of the HTTP_PROXY and HTTPS_PROXY env vars")
+	fs.StringVar(&noProxy, "sonarqube-no-proxy", getEnv("SONARQUBE_NO_PROXY", ""), "Comma-separated hosts, domains and CIDR ranges reached without sonarqube-proxy-url")
+	fs.IntVar(&cfg.SonarQubeMaxIdleConns, "sonarqube-max-idle-conns", env.getInt("SONARQUBE_MAX_IDLE_CONNS", 100), "Maximum number of idle connections to SonarQube kept for reuse")
+	fs.IntVar(&cfg.SonarQubeMaxIdleConnsPerHost, "sonarqube-max-idle-conns-per-host", env.getInt("SONARQUBE_MAX_IDLE_CONNS_PER_HOST", 10), "Maximum number of idle connections kept for reuse per SonarQube host")
+	fs.DurationVar(&cfg.SonarQubeIdleConnTimeout, "sonarqube-idle-conn-timeout", env.getDuration("SONARQUBE_IDLE_CONN_TIMEOUT", 90*time.Second), "How long an idle connection to SonarQube is kept for reuse")
+	fs.DurationVar(&cfg.SonarQubeKeepAlive, "sonarqube-keep-alive", env.getDuration("SONARQUBE_KEEP_ALIVE", 30*time.Second), "Period of TCP keep-alive probes on SonarQube connections, negative to disable them")
+	fs.DurationVar(&cfg.SonarQubeTimeout, "sonarqube-timeout", env.getDuration("SONARQUBE_TIMEOUT", 30*time.Second), "Timeout of a whole SonarQube request")
+	fs.DurationVar(&cfg.SonarQubeDialTimeout, "sonarqube-dial-timeout", env.getDuration("SONARQUBE_DIAL_TIMEOUT", 30*time.Second), "Timeout of opening a connection to SonarQube")
+	fs.DurationVar(&cfg.SonarQubeTLSHandshakeTimeout, "sonarqube-tls-handshake-timeout", env.getDuration("SONARQUBE_TLS_HANDSHAKE_TIMEOUT", 10*time.Second), "Timeout of the TLS handshake with SonarQube")
+	fs.DurationVar(&cfg.SonarQubeResponseHeaderTimeout, "sonarqube-response-header-timeout", env.getDuration("SONARQUBE_RESPONSE_HEADER_TIMEOUT", 0), "Timeout waiting for the response headers of SonarQube once the request is sent (0 disables it)")
+	fs.BoolVar(&cfg.SonarQubeHTTP2, "sonarqube-http2", env.getBool("SONARQUBE_HTTP2", true), "Use HTTP/2 with SonarQube when the server supports it")
+	fs.DurationVar(&cfg.MetricCatalogTTL, "metric-catalog-ttl", env.getDuration("EXPORTER_METRIC_CATALOG_TTL", 10*time.Minute), "How long the SonarQube metric catalog is cached before being refreshed in the background (0 fetches it on every scrape)")
+	fs.BoolVar(&cfg.IncrementalRefresh, "incremental-refresh", env.getBool("EXPORTER_INCREMENTAL_REFRESH", false), "Fetch the measures of a project only when it was re-analyzed since the previous scrape")
+	fs.DurationVar(&cfg.FullResyncInterval, "full-resync-interval", env.getDuration("EXPORTER_FULL_RESYNC_INTERVAL", time.Hour), "Interval between fetches of the measures of all projects with incremental-refresh (0 disables it)")
+	fs.StringVar(&cfg.SnapshotFile, "snapshot-file", getEnv("EXPORTER_SNAPSHOT_FILE", ""), "File persisting the last collected data, served after a restart until the first refresh completes")
+	fs.BoolVar(&cfg.AnalysisTimestamps, "analysis-timestamps", env.getBool("EXPORTER_ANALYSIS_TIMESTAMPS", false), "Stamp the measures of recently analyzed projects with the analysis date instead of the scrape time")
+	fs.DurationVar(&cfg.TimestampWindow, "analysis-timestamps-window", env.getDuration("EXPORTER_ANALYSIS_TIMESTAMPS_WINDOW", 5*time.Minute), "Age up to which measures keep the analysis date with analysis-timestamps, beyond which they are stamped at scrape time")
+	fs.BoolVar(&cfg.Webhook, "webhook", env.getBool("EXPORTER_WEBHOOK", false), "Receive SonarQube analysis webhooks on /webhook to refresh projects as soon as they are analyzed")
+	fs.StringVar(&cfg.WebhookSecret, "webhook-secret", getEnv("EXPORTER_WEBHOOK_SECRET", ""), "Secret of the SonarQube webhook, used to verify its signature")
+	fs.StringVar(&notifyURLs, "notify-urls", getEnv("EXPORTER_NOTIFY_URLS", ""), "Comma-separated URLs notified with a POST when the quality gate status of a project changes")
+	fs.StringVar(&cfg.NotifyTemplateFile, "notify-template-file", getEnv("EXPORTER_NOTIFY_TEMPLATE_FILE", ""), "text/template file rendering the body of the notifications, instead of the Slack-compatible body")
+	fs.BoolVar(&cfg.RatingLabels, "rating-labels", env.getBool("EXPORTER_RATING_LABELS", false), "Also expose rating metrics as sonarqube_rating series labelled with the rating letter")
+	fs.DurationVar(&cfg.StaleThreshold, "stale-threshold", env.getDuration("EXPORTER_STALE_THRESHOLD", 0), "Age after which a project analysis is reported as stale (0 disables the sonarqube_project_stale gauge)")
+	fs.StringVar(&tagLabelPrefixes, "tag-label-prefixes", getEnv("EXPORTER_TAG_LABEL_PREFIXES", ""), "Comma-separated project tag prefixes promoted to labels on every measure (e.g. team:,tier:)")
+	fs.BoolVar(&cfg.AnalysisHistory, "analysis-history", env.getBool("EXPORTER_ANALYSIS_HISTORY", false), "Export the version, revision and event counts from the analysis history of each project")
+	fs.BoolVar(&cfg.Hotspots, "hotspots", env.getBool("EXPORTER_HOTSPOTS", false), "Export security hotspot counts and review percentage of each project")
+	fs.StringVar(&securityStandards, "security-report-standards", getEnv("EXPORTER_SECURITY_REPORT_STANDARDS", ""), "Comma-separated security standards exported from security reports (e.g. owaspTop10-2021,cwe)")
+	fs.BoolVar(&cfg.QualityProfiles, "quality-profiles", env.getBool("EXPORTER_QUALITY_PROFILES", false), "Export the quality profile inventory and the quality profiles used by each project")
+	fs.StringVar(&standardProfiles, "standard-quality-profiles", getEnv("EXPORTER_STANDARD_QUALITY_PROFILES", ""), "Comma-separated names of the standard quality profiles project profiles should inherit from")
+	fs.BoolVar(&cfg.License, "license", env.getBool("EXPORTER_LICENSE", false), "Export license and lines of code consumption of commercial editions")
+	fs.BoolVar(&cfg.TokenMonitoring, "token-monitoring", env.getBool("EXPORTER_TOKEN_MONITORING", false), "Export the validity and expiration date of the SonarQube token")
+	fs.BoolVar(&cfg.Once, "once", env.getBool("EXPORTER_ONCE", false), "Run one collection, write it to the output file and exit, instead of serving metrics")
+	fs.StringVar(&cfg.Output, "output", getEnv("EXPORTER_OUTPUT", ""), "File written with once, in the text exposition format, or by the backfill subcommand")
+	fs.StringVar(&cfg.PushURL, "push-url", getEnv("EXPORTER_PUSH_URL", ""), "URL of a Pushgateway the metrics are pushed to, for Prometheus servers that can't reach the exporter")
+	fs.StringVar(&cfg.PushJob, "push-job", getEnv("EXPORTER_PUSH_JOB", "sonarqube_exporter"), "Job grouping key of the pushed metrics")
+	fs.StringVar(&cfg.PushInstance, "push-instance", getEnv("EXPORTER_PUSH_INSTANCE", ""), "Instance grouping key of the pushed metrics (defaults to the hostname)")
+	fs.StringVar(&cfg.PushUsername, "push-username", getEnv("EXPORTER_PUSH_USERNAME", ""), "Username of the basic authentication to the Pushgateway")
+	fs.StringVar(&cfg.PushPassword, "push-password", getEnv("EXPORTER_PUSH_PASSWORD", ""), "Password of the basic authentication to the Pushgateway")
+	fs.DurationVar(&cfg.PushInterval, "push-interval", env.getDuration("EXPORTER_PUSH_INTERVAL", time.Minute), "Interval between pushes of the metrics to the Pushgateway")
+	fs.DurationVar(&cfg.PushTimeout, "push-timeout", env.getDuration("EXPORTER_PUSH_TIMEOUT", 30*time.Second), "Timeout of a request to the Pushgateway")
+	fs.BoolVar(&cfg.PushDeleteOnShutdown, "push-delete-on-shutdown", env.getBool("EXPORTER_PUSH_DELETE_ON_SHUTDOWN", false), "Delete the pushed metrics from the Pushgateway when the exporter shuts down")
+	fs.StringVar(&cfg.SonarQubeTokenName, "sonarqube-token-name", getEnv("SONARQUBE_TOKEN_NAME", ""), "Name of the SonarQube token, used to monitor its expiration when the user has several tokens")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
+	if err := env.err(); err != nil {
+		return nil, err
+	}
+
+	cfg.TagLabelPrefixes = splitList(tagLabelPrefixes)
+	cfg.SecurityStandards = splitList(securityStandards)
+	cfg.StandardProfiles = splitList(standardProfiles)
+	cfg.SonarQubeNoProxy = splitList(noProxy)
+	cfg.NotifyURLs = splitList(notifyURLs)
 
 	// Validate required fields
 	if cfg.SonarQubeURL == "" {
 		return nil, fmt.Errorf("sonarqube-url is required (set via flag or SONARQUBE_URL env var)")
 	}
-	if cfg.SonarQubeToken == "" {
-		return nil, fmt.Errorf("sonarqube-token is required (set via flag or SONARQUBE_TOKEN env var)")
+	if cfg.SonarQubeTokenFile != "" {
+		if cfg.SonarQubeToken != "" {
+			return nil, fmt.Errorf("sonarqube-token and sonarqube-token-file are mutually exclusive")
+		}
+		if cfg.SonarQubeTokenFileInterval <= 0 {
+			return nil, fmt.Errorf("sonarqube-token-file-interval must be positive")
+		}
+
+		token, err := sonarqube.ReadTokenFile(cfg.SonarQubeTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SonarQubeToken = token
+	}
+
+	mode, err := sonarqube.ParseAuthMode(authMode)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SonarQubeAuthMode = mode
+
+	switch {
+	case mode == sonarqube.AuthBasic && cfg.SonarQubeUsername == "":
+		return nil, fmt.Errorf("sonarqube-username is required with the basic authentication mode (set via flag or SONARQUBE_USERNAME env var)")
+	case mode == sonarqube.AuthHeader && cfg.SonarQubeAuthHeader == "":
+		return nil, fmt.Errorf("sonarqube-auth-header is required with the header authentication mode (set via flag or SONARQUBE_AUTH_HEADER env var)")
+	case mode != sonarqube.AuthBasic && cfg.SonarQubeToken == "":
+		return nil, fmt.Errorf("sonarqube-token is required (set via flag, SONARQUBE_TOKEN env var or sonarqube-token-file)")
+	}
+
+	if (cfg.SonarQubeCertFile == "") != (cfg.SonarQubeKeyFile == "") {
+		return nil, fmt.Errorf("sonarqube-cert-file and sonarqube-key-file must be set together")
+	}
+
+	version, err := sonarqube.ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SonarQubeTLSMinVersion = version
+
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid sonarqube-proxy-url %q", proxyURL)
+		}
+		cfg.SonarQubeProxyURL = parsed
+	}
+
+	if cfg.Once && cfg.Output == "" {
+		return nil, fmt.Errorf("output is required with once (set via flag or EXPORTER_OUTPUT env var)")
+	}
+	if cfg.Once && cfg.AnalysisTimestamps {
+		return nil, fmt.Errorf("once and analysis-timestamps are mutually exclusive, the textfile collector of node_exporter rejects timestamps")
+	}
+
+	if cfg.PushURL != "" {
+		parsed, err := url.Parse(cfg.PushURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid push-url %q", cfg.PushURL)
+		}
+		if cfg.Once {
+			return nil, fmt.Errorf("push-url and once are mutually exclusive")
+		}
+		if cfg.AnalysisTimestamps {
+			return nil, fmt.Errorf("push-url and analysis-timestamps are mutually exclusive, the Pushgateway rejects timestamps")
+		}
+		if cfg.PushInterval <= 0 {
+			return nil, fmt.Errorf("push-interval must be positive")
+		}
+		if cfg.PushInstance == "" {
+			cfg.PushInstance, _ = os.Hostname()
+		}
+	}
+
+	if cfg.NotifyTemplateFile != "" && len(cfg.NotifyURLs) == 0 {
+		return nil, fmt.Errorf("notify-template-file requires notify-urls (set via flag or EXPORTER_NOTIFY_URLS env var)")
+	}
+
+	if cfg.Webhook && cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("webhook-secret is required with webhook (set via flag or EXPORTER_WEBHOOK_SECRET env var)")
 	}
 
 	return cfg, nil
 }
 
+// LoadBackfill loads the configuration of the backfill subcommand, whose flags come on top of the
+// SonarQube connection and metric ones
+func LoadBackfill(args []string) (*Config, *Backfill, error) {
+	return LoadBackfillWithFlagSet(flag.NewFlagSet("backfill", flag.ExitOnError), args)
+}
+
+// LoadBackfillWithFlagSet loads the backfill configuration with a custom flag set (useful for testing)
+func LoadBackfillWithFlagSet(fs *flag.FlagSet, args []string) (*Config, *Backfill, error) {
+	backfill := &Backfill{}
+	var projects, metrics, from, to string
+
+	fs.StringVar(&projects, "projects", "", "Comma-separated keys of the projects to backfill (defaults to all projects)")
+	fs.StringVar(&metrics, "metrics", "", "Comma-separated keys of the metrics to backfill (defaults to all numeric metrics)")
+	fs.StringVar(&from, "from", "", "Date from which analyses are backfilled, as YYYY-MM-DD or RFC 3339 (defaults to the first analysis)")
+	fs.StringVar(&to, "to", "", "Date before which analyses are backfilled, as YYYY-MM-DD or RFC 3339 (defaults to the last analysis included)")
+
+	cfg, err := LoadWithFlagSet(fs, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backfill.Output = cfg.Output
+	if backfill.Output == "" {
+		backfill.Output = "-"
+	}
+	backfill.Projects = splitList(projects)
+	backfill.Metrics = splitList(metrics)
+
+	if backfill.From, err = parseDate(from); err != nil {
+		return nil, nil, fmt.Errorf("invalid from date: %w", err)
+	}
+	if backfill.To, err = parseDate(to); err != nil {
+		return nil, nil, fmt.Errorf("invalid to date: %w", err)
+	}
+	if !backfill.From.IsZero() && !backfill.To.IsZero() && backfill.To.Before(backfill.From) {
+		return nil, nil, fmt.Errorf("to date %s is before from date %s", to, from)
+	}
+
+	return cfg, backfill, nil
+}
+
+// parseDate parses a date as YYYY-MM-DD or RFC 3339. An empty value is the zero time.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // getEnv returns the value of an environment variable or a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -55,7 +333,104 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// envVars reads the typed environment variables used as flag defaults. A value that doesn't parse
+// is recorded, so that an invalid environment variable fails the configuration like an invalid flag.
+type envVars struct {
+	errs []error
+}
+
+// getBool returns the boolean value of an environment variable or a default value
+func (e *envVars) getBool(key string, defaultValue bool) bool {
+	return getEnvParsed(e, key, defaultValue, strconv.ParseBool)
+}
+
+// getDuration returns the duration value of an environment variable or a default value
+func (e *envVars) getDuration(key string, defaultValue time.Duration) time.Duration {
+	return getEnvParsed(e, key, defaultValue, time.ParseDuration)
+}
+
+// getInt returns the integer value of an environment variable or a default value
+func (e *envVars) getInt(key string, defaultValue int) int {
+	return getEnvParsed(e, key, defaultValue, strconv.Atoi)
+}
+
+// err returns the errors of the environment variables that failed to parse, if any
+func (e *envVars) err() error {
+	return errors.Join(e.errs...)
+}
+
+// getEnvParsed returns the parsed value of an environment variable, or a default value when it is
+// unset or fails to parse, recording the error
+func getEnvParsed[T any](e *envVars, key string, defaultValue T, parse func(string) (T, error)) T {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("invalid value %q for %s env var: %w", value, key, err))
+		return defaultValue
+	}
+	return parsed
+}
+
+// splitList splits a comma-separated list, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Address returns the full address (host:port) to bind the server
 func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
+
+// TLSOptions returns the TLS settings of the connections to SonarQube
+func (c *Config) TLSOptions() sonarqube.TLSOptions {
+	return sonarqube.TLSOptions{
+		URL:                c.SonarQubeURL,
+		CAFile:             c.SonarQubeCAFile,
+		CertFile:           c.SonarQubeCertFile,
+		KeyFile:            c.SonarQubeKeyFile,
+		MinVersion:         c.SonarQubeTLSMinVersion,
+		ServerName:         c.SonarQubeTLSServerName,
+		InsecureSkipVerify: c.SonarQubeTLSInsecureSkipVerify,
+	}
+}
+
+// TransportOptions returns the connection settings of the SonarQube client
+func (c *Config) TransportOptions() sonarqube.TransportOptions {
+	return sonarqube.TransportOptions{
+		ProxyURL:              c.SonarQubeProxyURL,
+		NoProxy:               c.SonarQubeNoProxy,
+		MaxIdleConns:          c.SonarQubeMaxIdleConns,
+		MaxIdleConnsPerHost:   c.SonarQubeMaxIdleConnsPerHost,
+		IdleConnTimeout:       c.SonarQubeIdleConnTimeout,
+		KeepAlive:             c.SonarQubeKeepAlive,
+		Timeout:               c.SonarQubeTimeout,
+		DialTimeout:           c.SonarQubeDialTimeout,
+		TLSHandshakeTimeout:   c.SonarQubeTLSHandshakeTimeout,
+		ResponseHeaderTimeout: c.SonarQubeResponseHeaderTimeout,
+		DisableHTTP2:          !c.SonarQubeHTTP2,
+	}
+}
+
+// PushOptions returns the options of the push of the metrics to the Pushgateway
+func (c *Config) PushOptions() server.PushOptions {
+	return server.PushOptions{
+		URL:              c.PushURL,
+		Job:              c.PushJob,
+		Instance:         c.PushInstance,
+		Username:         c.PushUsername,
+		Password:         c.PushPassword,
+		Interval:         c.PushInterval,
+		Timeout:          c.PushTimeout,
+		DeleteOnShutdown: c.PushDeleteOnShutdown,
+	}
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"flag"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad_WithEnvironmentVariables(t *testing.T) {
@@ -62,6 +65,332 @@ func TestLoad_MissingSonarQubeURL(t *testing.T) {
 	}
 }
 
+func TestLoad_TokenFile(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	defer os.Unsetenv("SONARQUBE_URL")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := LoadWithFlagSet(fs, []string{"-sonarqube-token-file", tokenFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.SonarQubeToken != "file-token" {
+		t.Errorf("Expected SonarQubeToken to be 'file-token', got: %s", cfg.SonarQubeToken)
+	}
+
+	if cfg.SonarQubeTokenFile != tokenFile {
+		t.Errorf("Expected SonarQubeTokenFile to be '%s', got: %s", tokenFile, cfg.SonarQubeTokenFile)
+	}
+}
+
+func TestLoad_TokenFileInterval(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	defer os.Unsetenv("SONARQUBE_URL")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	for _, interval := range []string{"0s", "-1s"} {
+		t.Run(interval, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			args := []string{"-sonarqube-token-file", tokenFile, "-sonarqube-token-file-interval", interval}
+			if _, err := LoadWithFlagSet(fs, args); err == nil {
+				t.Errorf("Expected error for a token file interval of %s, got nil", interval)
+			}
+		})
+	}
+}
+
+func TestLoad_TokenAndTokenFile(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	os.Setenv("SONARQUBE_TOKEN_FILE", "/var/run/secrets/token")
+	defer func() {
+		os.Unsetenv("SONARQUBE_URL")
+		os.Unsetenv("SONARQUBE_TOKEN")
+		os.Unsetenv("SONARQUBE_TOKEN_FILE")
+	}()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := LoadWithFlagSet(fs, []string{}); err == nil {
+		t.Fatal("Expected error when both a token and a token file are set, got nil")
+	}
+}
+
+func TestLoad_AuthModes(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	defer os.Unsetenv("SONARQUBE_URL")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "basic without token", args: []string{"-sonarqube-auth-mode", "basic", "-sonarqube-username", "exporter"}, shouldErr: false},
+		{name: "basic without username", args: []string{"-sonarqube-auth-mode", "basic"}, shouldErr: true},
+		{name: "header without header name", args: []string{"-sonarqube-auth-mode", "header", "-sonarqube-token", "test-token"}, shouldErr: true},
+		{name: "unsupported mode", args: []string{"-sonarqube-auth-mode", "kerberos", "-sonarqube-token", "test-token"}, shouldErr: true},
+		{name: "auto with token", args: []string{"-sonarqube-auth-mode", "auto", "-sonarqube-token", "test-token"}, shouldErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_TransportDefaults(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	os.Setenv("SONARQUBE_MAX_IDLE_CONNS_PER_HOST", "20")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+	defer os.Unsetenv("SONARQUBE_MAX_IDLE_CONNS_PER_HOST")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := LoadWithFlagSet(fs, []string{"-sonarqube-http2=false"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	opts := cfg.TransportOptions()
+	if opts.MaxIdleConnsPerHost != 20 {
+		t.Errorf("Expected 20 idle connections per host, got: %d", opts.MaxIdleConnsPerHost)
+	}
+	if opts.Timeout != 30*time.Second {
+		t.Errorf("Expected 30s timeout, got: %v", opts.Timeout)
+	}
+	if !opts.DisableHTTP2 {
+		t.Error("Expected HTTP/2 to be disabled")
+	}
+	if opts.ProxyURL != nil {
+		t.Errorf("Expected no proxy, got: %v", opts.ProxyURL)
+	}
+}
+
+func TestLoad_TLS(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "certificate and key", args: []string{"-sonarqube-cert-file", "client.pem", "-sonarqube-key-file", "client-key.pem"}, shouldErr: false},
+		{name: "certificate without key", args: []string{"-sonarqube-cert-file", "client.pem"}, shouldErr: true},
+		{name: "minimum version", args: []string{"-sonarqube-tls-min-version", "1.3"}, shouldErr: false},
+		{name: "unsupported minimum version", args: []string{"-sonarqube-tls-min-version", "2.0"}, shouldErr: true},
+		{name: "proxy URL", args: []string{"-sonarqube-proxy-url", "http://proxy.example.com:3128", "-sonarqube-no-proxy", "localhost,.corp"}, shouldErr: false},
+		{name: "invalid proxy URL", args: []string{"-sonarqube-proxy-url", "proxy.example.com"}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Webhook(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "disabled", args: []string{}, shouldErr: false},
+		{name: "with secret", args: []string{"-webhook", "-webhook-secret", "webhook-secret"}, shouldErr: false},
+		{name: "without secret", args: []string{"-webhook"}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Notifications(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "URLs", args: []string{"-notify-urls", "https://hooks.example.com/1, https://hooks.example.com/2"}, shouldErr: false},
+		{name: "template", args: []string{"-notify-urls", "https://hooks.example.com/1", "-notify-template-file", "notification.tmpl"}, shouldErr: false},
+		{name: "template without URLs", args: []string{"-notify-template-file", "notification.tmpl"}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Once(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "with output", args: []string{"-once", "-output", "sonarqube.prom"}, shouldErr: false},
+		{name: "without output", args: []string{"-once"}, shouldErr: true},
+		{name: "with analysis timestamps", args: []string{"-once", "-output", "sonarqube.prom", "-analysis-timestamps"}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Push(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	tests := []struct {
+		name      string
+		args      []string
+		shouldErr bool
+	}{
+		{name: "push URL", args: []string{"-push-url", "http://pushgateway:9091", "-push-instance", "exporter-1"}, shouldErr: false},
+		{name: "invalid push URL", args: []string{"-push-url", "pushgateway:9091"}, shouldErr: true},
+		{name: "with once", args: []string{"-push-url", "http://pushgateway:9091", "-once", "-output", "sonarqube.prom"}, shouldErr: true},
+		{name: "with analysis timestamps", args: []string{"-push-url", "http://pushgateway:9091", "-analysis-timestamps"}, shouldErr: true},
+		{name: "zero interval", args: []string{"-push-url", "http://pushgateway:9091", "-push-interval", "0s"}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			cfg, err := LoadWithFlagSet(fs, tt.args)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			opts := cfg.PushOptions()
+			if opts.URL != "http://pushgateway:9091" || opts.Job != "sonarqube_exporter" || opts.Instance != "exporter-1" || opts.Interval != time.Minute {
+				t.Errorf("Unexpected push options: %+v", opts)
+			}
+		})
+	}
+}
+
+func TestLoadBackfill(t *testing.T) {
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	os.Setenv("SONARQUBE_TOKEN", "test-token")
+	defer os.Unsetenv("SONARQUBE_URL")
+	defer os.Unsetenv("SONARQUBE_TOKEN")
+
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	args := []string{"-projects", "project1,project2", "-metrics", "bugs", "-from", "2023-01-01", "-to", "2024-06-01T12:00:00+02:00", "-output", "history.om", "-tag-label-prefixes", "team:"}
+	cfg, backfill, err := LoadBackfillWithFlagSet(fs, args)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(backfill.Projects) != 2 || len(backfill.Metrics) != 1 || backfill.Output != "history.om" {
+		t.Errorf("Unexpected backfill configuration: %+v", backfill)
+	}
+	if !backfill.From.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected from 2023-01-01, got: %v", backfill.From)
+	}
+	if !backfill.To.Equal(time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected to 2024-06-01T10:00:00Z, got: %v", backfill.To)
+	}
+	if len(cfg.TagLabelPrefixes) != 1 {
+		t.Errorf("Expected the exporter flags to be accepted, got: %v", cfg.TagLabelPrefixes)
+	}
+
+	invalid := [][]string{
+		{"-from", "01/01/2023"},
+		{"-from", "2024-01-01", "-to", "2023-01-01"},
+	}
+	for _, args := range invalid {
+		fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+		if _, _, err := LoadBackfillWithFlagSet(fs, args); err == nil {
+			t.Errorf("Expected error for %v, got nil", args)
+		}
+	}
+}
+
 func TestAddress(t *testing.T) {
 	cfg := &Config{
 		Host: "localhost",
@@ -112,3 +441,78 @@ func TestGetEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvVars(t *testing.T) {
+	os.Setenv("TEST_BOOL_VAR", "true")
+	defer os.Unsetenv("TEST_BOOL_VAR")
+	os.Setenv("TEST_INVALID_BOOL_VAR", "maybe")
+	defer os.Unsetenv("TEST_INVALID_BOOL_VAR")
+	os.Setenv("TEST_INVALID_DURATION_VAR", "7d")
+	defer os.Unsetenv("TEST_INVALID_DURATION_VAR")
+
+	env := &envVars{}
+	if !env.getBool("TEST_BOOL_VAR", false) {
+		t.Error("Expected 'true' to be parsed as true")
+	}
+	if !env.getBool("NON_EXISTENT_VAR", true) {
+		t.Error("Expected missing variable to fall back to the default")
+	}
+	if err := env.err(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Invalid values fall back to the default, and are reported
+	if env.getBool("TEST_INVALID_BOOL_VAR", false) {
+		t.Error("Expected invalid value to fall back to the default")
+	}
+	if env.getDuration("TEST_INVALID_DURATION_VAR", time.Hour) != time.Hour {
+		t.Error("Expected invalid duration to fall back to the default")
+	}
+	err := env.err()
+	if err == nil {
+		t.Fatal("Expected an error for the invalid values, got nil")
+	}
+	for _, key := range []string{"TEST_INVALID_BOOL_VAR", "TEST_INVALID_DURATION_VAR"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected the error to name %s, got: %v", key, err)
+		}
+	}
+}
+
+func TestLoad_InvalidEnvironmentVariables(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{key: "EXPORTER_STALE_THRESHOLD", value: "7d"},
+		{key: "EXPORTER_HOTSPOTS", value: "yes please"},
+		{key: "SONARQUBE_MAX_IDLE_CONNS", value: "many"},
+	}
+
+	os.Setenv("SONARQUBE_URL", "https://sonar.example.com")
+	defer os.Unsetenv("SONARQUBE_URL")
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			os.Setenv(tt.key, tt.value)
+			defer os.Unsetenv(tt.key)
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			_, err := LoadWithFlagSet(fs, []string{})
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("Expected an error for %s=%s, got: %v", tt.key, tt.value, err)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	items := splitList(" team:, tier: ,,")
+	if len(items) != 2 || items[0] != "team:" || items[1] != "tier:" {
+		t.Errorf("Expected [team: tier:], got: %q", items)
+	}
+
+	if items := splitList(""); len(items) != 0 {
+		t.Errorf("Expected no items, got: %q", items)
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// analysisEventCategories are the analysis event categories counted per project
+var analysisEventCategories = []string{
+	sonarqube.EventCategoryQualityGate,
+	sonarqube.EventCategoryVersion,
+	sonarqube.EventCategoryQualityProfile,
+}
+
+// WithAnalysisHistory enables the metrics built from the analysis history of each project:
+// the version and SCM revision of the latest analysis, and the number of analysis events by category
+func WithAnalysisHistory(enabled bool) Option {
+	return func(c *Collector) {
+		c.analysisHistory = enabled
+	}
+}
+
+// collectAnalyses exports the latest analysis info and the analysis event counts of a project
+func (c *Collector) collectAnalyses(ch chan<- prometheus.Metric, project sonarqube.Component) {
+	// Projects that have never been analyzed have no history
+	if project.AnalysisDate == "" {
+		return
+	}
+
+	analysis, err := c.client.GetLatestAnalysis(project.Key)
+	if err != nil {
+		log.Printf("Error fetching latest analysis for project %s: %v", project.Key, err)
+		c.recordError(err)
+		return
+	}
+	if analysis == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.analysisInfo,
+		prometheus.GaugeValue,
+		1,
+		project.Key,
+		project.Name,
+		analysis.ProjectVersion,
+		analysis.Revision,
+		analysis.DetectedCI,
+	)
+
+	// Each count is the number of analyses kept in the history with an event of the category.
+	// It is a gauge, as it decreases when SonarQube housekeeping deletes old analyses.
+	for _, category := range analysisEventCategories {
+		count, err := c.client.CountAnalysisEvents(project.Key, category)
+		if err != nil {
+			log.Printf("Error counting %s analysis events for project %s: %v", category, project.Key, err)
+			c.recordError(err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.analysisEvents,
+			prometheus.GaugeValue,
+			float64(count),
+			project.Key,
+			category,
+		)
+	}
+}
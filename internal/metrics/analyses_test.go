@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollectAnalyses(t *testing.T) {
+	eventCounts := map[string]int{
+		sonarqube.EventCategoryQualityGate:    3,
+		sonarqube.EventCategoryVersion:        2,
+		sonarqube.EventCategoryQualityProfile: 0,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		category := r.URL.Query().Get("category")
+		response := sonarqube.ProjectAnalysesResponse{
+			Paging: sonarqube.Paging{PageIndex: 1, PageSize: 1, Total: eventCounts[category]},
+		}
+		if category == "" {
+			response.Paging.Total = 12
+			response.Analyses = []sonarqube.Analysis{
+				{Key: "AU-1", ProjectVersion: "2.0.0", Revision: "def456", DetectedCI: "Jenkins"},
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithAnalysisHistory(true))
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.collectAnalyses(ch, sonarqube.Component{Key: "project1", Name: "Project 1", AnalysisDate: "2024-01-15T10:30:00+0100"})
+	collector.collectAnalyses(ch, sonarqube.Component{Key: "project2", Name: "Project 2"})
+	close(ch)
+
+	var info []map[string]string
+	events := make(map[string]float64)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Failed to write metric: %v", err)
+		}
+
+		labels := make(map[string]string)
+		for _, pair := range pb.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+
+		switch m.Desc() {
+		case collector.analysisInfo:
+			info = append(info, labels)
+		case collector.analysisEvents:
+			if pb.GetGauge() == nil {
+				t.Errorf("Expected the %s event count to be a gauge", labels["category"])
+			}
+			if labels["project_key"] != "project1" {
+				t.Errorf("Expected only project1 events, got: %s", labels["project_key"])
+			}
+			events[labels["category"]] = pb.GetGauge().GetValue()
+		}
+	}
+
+	// Only project1 has been analyzed
+	if len(info) != 1 {
+		t.Fatalf("Expected 1 analysis info metric, got: %d", len(info))
+	}
+	expected := map[string]string{
+		"project_key":     "project1",
+		"project_name":    "Project 1",
+		"project_version": "2.0.0",
+		"revision":        "def456",
+		"detected_ci":     "Jenkins",
+	}
+	for name, value := range expected {
+		if info[0][name] != value {
+			t.Errorf("Expected analysis info label %s to be '%s', got: '%s'", name, value, info[0][name])
+		}
+	}
+
+	if len(events) != len(analysisEventCategories) {
+		t.Errorf("Expected %d analysis events metrics, got: %d", len(analysisEventCategories), len(events))
+	}
+	for category, count := range eventCounts {
+		if value, ok := events[category]; !ok || value != float64(count) {
+			t.Errorf("Expected %d %s events, got: %v", count, category, events[category])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithAnalysisTimestamps stamps the measures and quality gate status of a project with the date
+// of its last analysis, so that step changes line up with the analysis in graphs.
+//
+// Prometheus only looks back 5 minutes for the last sample of a series, and rejects samples older
+// than the last one of their series. Samples therefore keep the analysis date only while it is
+// younger than the window, and after the previous collection of the exporter. Otherwise, they are
+// stamped at scrape time as usual.
+func WithAnalysisTimestamps(enabled bool, window time.Duration) Option {
+	return func(c *Collector) {
+		c.analysisTimestamps = enabled
+		c.timestampWindow = window
+	}
+}
+
+// analysisTimestamp returns the timestamp of the measures of a project collected at the given time,
+// or the zero time to stamp them at scrape time. The projects stamped with their analysis date
+// are recorded in stamped, for the next collection.
+func (c *Collector) analysisTimestamp(project sonarqube.Component, now time.Time, stamped map[string]time.Time) time.Time {
+	if !c.analysisTimestamps || project.AnalysisDate == "" {
+		return time.Time{}
+	}
+
+	analysisDate, err := sonarqube.ParseDateTime(project.AnalysisDate)
+	if err != nil || analysisDate.After(now) || now.Sub(analysisDate) > c.timestampWindow {
+		return time.Time{}
+	}
+
+	// Samples of the previous collection are at most as recent as it, unless they already had
+	// this analysis date: an older analysis date would be out of order
+	if !analysisDate.After(c.lastCollection) && !c.stampedAnalyses[project.Key].Equal(analysisDate) {
+		return time.Time{}
+	}
+
+	stamped[project.Key] = analysisDate
+	return analysisDate
+}
+
+// withTimestamp stamps a metric with the timestamp, unless it is zero
+func withTimestamp(metric prometheus.Metric, timestamp time.Time) prometheus.Metric {
+	if timestamp.IsZero() {
+		return metric
+	}
+	return prometheus.NewMetricWithTimestamp(timestamp, metric)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+func TestAnalysisTimestamps(t *testing.T) {
+	analyzed := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+	project := sonarqube.Component{Key: "project1", AnalysisDate: analyzed.Format(sonarqube.DateTimeLayout)}
+
+	server := newFleetServer(project)
+	defer server.Close()
+
+	now := analyzed
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithAnalysisTimestamps(true, 5*time.Minute))
+	collector.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		update   func()
+		expected time.Time
+	}{
+		{
+			name:     "recent analysis is stamped with its date",
+			update:   func() { now = analyzed.Add(time.Minute) },
+			expected: analyzed,
+		},
+		{
+			name:     "next scrape keeps the analysis date",
+			update:   func() { now = analyzed.Add(2 * time.Minute) },
+			expected: analyzed,
+		},
+		{
+			name:   "analysis older than the window is stamped at scrape time",
+			update: func() { now = analyzed.Add(10 * time.Minute) },
+		},
+		{
+			name: "analysis before the previous scrape is stamped at scrape time",
+			update: func() {
+				now = analyzed.Add(11 * time.Minute)
+				project.AnalysisDate = analyzed.Add(9 * time.Minute).Format(sonarqube.DateTimeLayout)
+				server.setProjects(project)
+			},
+		},
+		{
+			name: "analysis after the previous scrape is stamped with its date",
+			update: func() {
+				now = analyzed.Add(12 * time.Minute)
+				project.AnalysisDate = analyzed.Add(11*time.Minute + 30*time.Second).Format(sonarqube.DateTimeLayout)
+				server.setProjects(project)
+			},
+			expected: analyzed.Add(11*time.Minute + 30*time.Second),
+		},
+	}
+
+	for _, step := range steps {
+		step.update()
+		families := gather(t, collector)
+
+		for _, name := range []string{"sonarqube_bugs", "sonarqube_project_info"} {
+			if _, exists := families[name]; !exists {
+				t.Fatalf("%s: expected metric %s to be collected", step.name, name)
+			}
+		}
+
+		bugs := families["sonarqube_bugs"].GetMetric()[0]
+		switch {
+		case step.expected.IsZero() && bugs.TimestampMs != nil:
+			t.Errorf("%s: expected no timestamp, got: %v", step.name, time.UnixMilli(bugs.GetTimestampMs()).UTC())
+		case !step.expected.IsZero() && bugs.GetTimestampMs() != step.expected.UnixMilli():
+			t.Errorf("%s: expected timestamp %v, got: %v", step.name, step.expected, time.UnixMilli(bugs.GetTimestampMs()).UTC())
+		}
+
+		// Only measures carry the analysis timestamp
+		if info := families["sonarqube_project_info"].GetMetric()[0]; info.TimestampMs != nil {
+			t.Errorf("%s: expected project info without timestamp", step.name)
+		}
+	}
+}
+
+func TestAnalysisTimestamps_Disabled(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client)
+
+	now := time.Now()
+	project := sonarqube.Component{Key: "project1", AnalysisDate: now.Format(sonarqube.DateTimeLayout)}
+
+	if timestamp := collector.analysisTimestamp(project, now, map[string]time.Time{}); !timestamp.IsZero() {
+		t.Errorf("Expected no timestamp when disabled, got: %v", timestamp)
+	}
+}
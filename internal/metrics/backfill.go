@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// BackfillOptions selects the measure history written by Backfill
+type BackfillOptions struct {
+	// ProjectKeys are the projects to backfill, all projects when empty
+	ProjectKeys []string
+	// MetricKeys are the metrics to backfill, all numeric metrics when empty
+	MetricKeys []string
+	// From and To bound the analyses to backfill, a zero time leaving the period open on that side
+	From time.Time
+	To   time.Time
+}
+
+// Backfill writes the measure history of projects in the OpenMetrics text format, each value
+// stamped with the date of its analysis, to be turned into TSDB blocks with
+// `promtool tsdb create-blocks-from openmetrics`. Series have the same names and labels as the
+// collected measures; rating state sets, quality gate statuses and the optional features are not
+// part of the history.
+func (c *Collector) Backfill(w io.Writer, opts BackfillOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics, err := c.client.GetMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to fetch metrics: %w", err)
+	}
+	c.updateMetricNames(metrics)
+
+	metricKeys, err := selectMetricKeys(c.getNumericMetricKeys(metrics), opts.MetricKeys)
+	if err != nil {
+		return err
+	}
+
+	projects, err := c.client.GetProjects()
+	if errors.Is(err, sonarqube.ErrProjectListTruncated) {
+		log.Printf("Warning: %v", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch projects: %w", err)
+	}
+
+	projects, err = selectProjects(projects, opts.ProjectKeys)
+	if err != nil {
+		return err
+	}
+
+	definitions := make(map[string]*sonarqube.Metric, len(metrics))
+	for i := range metrics {
+		definitions[metrics[i].Key] = &metrics[i]
+	}
+
+	families := make(map[string]*dto.MetricFamily)
+	samples := 0
+	for _, project := range projects {
+		if project.AnalysisDate == "" {
+			continue
+		}
+
+		history, err := c.client.GetMeasuresHistory(project.Key, metricKeys, opts.From, opts.To)
+		if err != nil {
+			return fmt.Errorf("failed to fetch measure history of project %s: %w", project.Key, err)
+		}
+
+		for _, measure := range history {
+			metricDef, exists := definitions[measure.Metric]
+			if !exists {
+				continue
+			}
+
+			for _, point := range measure.History {
+				metric, err := c.historyMetric(project, metricDef, point)
+				if err != nil {
+					log.Printf("Skipping value of metric %s for project %s at %s: %v", measure.Metric, project.Key, point.Date, err)
+					continue
+				}
+				if metric == nil {
+					continue
+				}
+
+				name := c.metricName(metricDef.Key)
+				family, exists := families[name]
+				if !exists {
+					family = &dto.MetricFamily{
+						Name: &name,
+						Help: &metricDef.Description,
+						Type: dto.MetricType_GAUGE.Enum(),
+					}
+					families[name] = family
+				}
+				family.Metric = append(family.Metric, metric)
+				samples++
+			}
+		}
+	}
+
+	if err := writeOpenMetrics(w, families); err != nil {
+		return err
+	}
+
+	log.Printf("Backfilled %d samples of %d metrics for %d projects", samples, len(families), len(projects))
+	return nil
+}
+
+// historyMetric builds the sample of a metric value at an analysis, or nil if the metric had no
+// value at that analysis
+func (c *Collector) historyMetric(project sonarqube.Component, metricDef *sonarqube.Metric, point sonarqube.HistoryValue) (*dto.Metric, error) {
+	if point.Value == "" {
+		return nil, nil
+	}
+
+	date, err := sonarqube.ParseDateTime(point.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseMetricValue(point.Value, metricDef.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	metric, err := prometheus.NewConstMetric(
+		c.getOrCreateMetricDesc(metricDef),
+		prometheus.GaugeValue,
+		value,
+		append([]string{project.Key, project.Name}, c.tagLabelValues(project)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var sample dto.Metric
+	if err := metric.Write(&sample); err != nil {
+		return nil, err
+	}
+	timestamp := date.UnixMilli()
+	sample.TimestampMs = &timestamp
+
+	return &sample, nil
+}
+
+// selectMetricKeys returns the requested metric keys, all numeric metrics if none is requested
+func selectMetricKeys(numericKeys, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return numericKeys, nil
+	}
+
+	for _, key := range requested {
+		if !slices.Contains(numericKeys, key) {
+			return nil, fmt.Errorf("metric %s is not a numeric metric of the catalog", key)
+		}
+	}
+	return requested, nil
+}
+
+// selectProjects returns the requested projects, all projects if none is requested
+func selectProjects(projects []sonarqube.Component, requested []string) ([]sonarqube.Component, error) {
+	if len(requested) == 0 {
+		return projects, nil
+	}
+
+	byKey := make(map[string]sonarqube.Component, len(projects))
+	for _, project := range projects {
+		byKey[project.Key] = project
+	}
+
+	selected := make([]sonarqube.Component, 0, len(requested))
+	for _, key := range requested {
+		project, exists := byKey[key]
+		if !exists {
+			return nil, fmt.Errorf("project %s not found", key)
+		}
+		selected = append(selected, project)
+	}
+	return selected, nil
+}
+
+// writeOpenMetrics writes metric families in the OpenMetrics text format, sorted by name, with the
+// samples of each series in timestamp order
+func writeOpenMetrics(w io.Writer, families map[string]*dto.MetricFamily) error {
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := families[name]
+		sort.SliceStable(family.Metric, func(i, j int) bool {
+			a, b := seriesKey(family.Metric[i]), seriesKey(family.Metric[j])
+			if a != b {
+				return a < b
+			}
+			return family.Metric[i].GetTimestampMs() < family.Metric[j].GetTimestampMs()
+		})
+
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+			return fmt.Errorf("failed to write metric %s: %w", name, err)
+		}
+	}
+
+	if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// seriesKey identifies the series of a sample by its label values
+func seriesKey(metric *dto.Metric) string {
+	values := make([]string, 0, len(metric.GetLabel()))
+	for _, label := range metric.GetLabel() {
+		values = append(values, label.GetValue())
+	}
+	return strings.Join(values, "\xff")
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// newHistoryServer creates a mock SonarQube server with the measure history of two projects, one
+// of which was never analyzed
+func newHistoryServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{
+					{Key: "bugs", Type: "INT", Name: "Bugs", Description: "Bugs", Domain: "Reliability"},
+					{Key: "coverage", Type: "PERCENT", Name: "Coverage", Description: "Coverage", Domain: "Coverage"},
+					{Key: "alert_status", Type: "LEVEL", Name: "Quality Gate Status"},
+				},
+				Total: 3,
+			})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging: sonarqube.Paging{Total: 2},
+				Components: []sonarqube.Component{
+					{Key: "project1", Name: "Project 1", AnalysisDate: "2024-01-15T10:30:00+0000"},
+					{Key: "project2", Name: "Project 2"},
+				},
+			})
+		case "/api/measures/search_history":
+			if r.URL.Query().Get("component") != "project1" {
+				t.Errorf("Expected the history of project1 only, got: %s", r.URL.Query().Get("component"))
+			}
+			history := []sonarqube.MeasureHistory{
+				{Metric: "bugs", History: []sonarqube.HistoryValue{
+					{Date: "2024-01-15T10:30:00+0000", Value: "3"},
+					{Date: "2024-01-10T08:00:00+0000", Value: "5"},
+				}},
+				{Metric: "coverage", History: []sonarqube.HistoryValue{
+					{Date: "2024-01-10T08:00:00+0000"},
+					{Date: "2024-01-15T10:30:00+0000", Value: "81.5"},
+				}},
+			}
+
+			response := sonarqube.MeasuresHistoryResponse{Paging: sonarqube.Paging{Total: 2}}
+			requested := strings.Split(r.URL.Query().Get("metrics"), ",")
+			for _, measure := range history {
+				if slices.Contains(requested, measure.Metric) {
+					response.Measures = append(response.Measures, measure)
+				}
+			}
+			json.NewEncoder(w).Encode(response)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestBackfill(t *testing.T) {
+	server := newHistoryServer(t)
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client)
+
+	var out bytes.Buffer
+	if err := collector.Backfill(&out, BackfillOptions{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := `# HELP sonarqube_bugs Bugs
+# TYPE sonarqube_bugs gauge
+sonarqube_bugs{domain="Reliability",project_key="project1",project_name="Project 1"} 5.0 1.7048736e+09
+sonarqube_bugs{domain="Reliability",project_key="project1",project_name="Project 1"} 3.0 1.7053146e+09
+# HELP sonarqube_coverage Coverage
+# TYPE sonarqube_coverage gauge
+sonarqube_coverage{domain="Coverage",project_key="project1",project_name="Project 1"} 81.5 1.7053146e+09
+# EOF
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected OpenMetrics output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestBackfill_Selection(t *testing.T) {
+	server := newHistoryServer(t)
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+
+	tests := []struct {
+		name      string
+		opts      BackfillOptions
+		shouldErr bool
+	}{
+		{name: "selected project and metric", opts: BackfillOptions{ProjectKeys: []string{"project1"}, MetricKeys: []string{"bugs"}}},
+		{name: "unknown project", opts: BackfillOptions{ProjectKeys: []string{"missing"}}, shouldErr: true},
+		{name: "non-numeric metric", opts: BackfillOptions{MetricKeys: []string{"alert_status"}}, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := NewCollector(client).Backfill(&out, tt.opts)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if strings.Contains(out.String(), "sonarqube_coverage") {
+				t.Errorf("Expected only the selected metric, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestBackfill_Period(t *testing.T) {
+	var from string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{Metrics: []sonarqube.Metric{{Key: "bugs", Type: "INT"}}, Total: 1})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging:     sonarqube.Paging{Total: 1},
+				Components: []sonarqube.Component{{Key: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"}},
+			})
+		case "/api/measures/search_history":
+			from = r.URL.Query().Get("from")
+			json.NewEncoder(w).Encode(sonarqube.MeasuresHistoryResponse{})
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	opts := BackfillOptions{From: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var out bytes.Buffer
+	if err := NewCollector(client).Backfill(&out, opts); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if from != "2023-01-01T00:00:00+0000" {
+		t.Errorf("Expected history from 2023-01-01, got: %s", from)
+	}
+	if out.String() != "# EOF\n" {
+		t.Errorf("Expected an empty OpenMetrics output, got: %q", out.String())
+	}
+}
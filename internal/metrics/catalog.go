@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"log"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMetricCatalogTTL caches the metric catalog for the TTL instead of fetching it on every
+// scrape. A zero TTL disables the cache.
+func WithMetricCatalogTTL(ttl time.Duration) Option {
+	return func(c *Collector) {
+		c.catalogTTL = ttl
+	}
+}
+
+// metricCatalog returns the metric catalog. It is fetched on the first scrape, or on every scrape
+// without a TTL. Once the TTL expires, the cached catalog is still returned while it is refreshed
+// in the background, so that scrapes never wait for it.
+func (c *Collector) metricCatalog() ([]sonarqube.Metric, error) {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+
+	if c.catalog == nil || c.catalogTTL <= 0 {
+		metrics, err := c.client.GetMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		c.setMetricCatalog(metrics)
+		return metrics, nil
+	}
+
+	if c.now().Sub(c.catalogFetched) >= c.catalogTTL && !c.catalogRefreshing {
+		c.catalogRefreshing = true
+		c.catalogRefresh.Add(1)
+		go c.refreshMetricCatalog()
+	}
+
+	return c.catalog, nil
+}
+
+// refreshMetricCatalog fetches the metric catalog in the background. On failure, the cached
+// catalog is kept and the refresh is retried on the next scrape.
+func (c *Collector) refreshMetricCatalog() {
+	defer c.catalogRefresh.Done()
+
+	metrics, err := c.client.GetMetrics()
+
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+	c.catalogRefreshing = false
+
+	if err != nil {
+		log.Printf("Error refreshing metric catalog, keeping the cached one: %v", err)
+		c.recordError(err)
+		return
+	}
+
+	c.setMetricCatalog(metrics)
+}
+
+// setMetricCatalog caches the metric catalog and logs the metrics added or removed since the
+// previous one, e.g. by installing or removing a plugin
+func (c *Collector) setMetricCatalog(metrics []sonarqube.Metric) {
+	if c.catalog != nil {
+		added, removed := diffMetricCatalogs(c.catalog, metrics)
+		if added > 0 || removed > 0 {
+			log.Printf("SonarQube metric catalog changed: %d metrics added, %d removed", added, removed)
+		}
+	}
+
+	c.catalog = metrics
+	c.catalogFetched = c.now()
+}
+
+// diffMetricCatalogs counts the metric keys added and removed between two catalogs
+func diffMetricCatalogs(previous, current []sonarqube.Metric) (added, removed int) {
+	keys := make(map[string]bool, len(previous))
+	for _, metric := range previous {
+		keys[metric.Key] = true
+	}
+
+	for _, metric := range current {
+		if keys[metric.Key] {
+			delete(keys, metric.Key)
+		} else {
+			added++
+		}
+	}
+
+	return added, len(keys)
+}
+
+// exportMetricCatalogSize exports the number of metrics of the catalog
+func (c *Collector) exportMetricCatalogSize(ch chan<- prometheus.Metric, metrics []sonarqube.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		c.catalogSize,
+		prometheus.GaugeValue,
+		float64(len(metrics)),
+	)
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// catalogServer is a mock SonarQube server whose metric catalog can be changed
+type catalogServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	metrics  []sonarqube.Metric
+	requests int
+}
+
+func newCatalogServer(metrics ...sonarqube.Metric) *catalogServer {
+	s := &catalogServer{metrics: metrics}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sonarqube.MetricsResponse{Metrics: s.metrics, Total: len(s.metrics)})
+	}))
+	return s
+}
+
+func (s *catalogServer) setMetrics(metrics ...sonarqube.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = metrics
+}
+
+func (s *catalogServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func TestMetricCatalog_Cache(t *testing.T) {
+	server := newCatalogServer(sonarqube.Metric{Key: "bugs"}, sonarqube.Metric{Key: "legacy_metric"})
+	defer server.Close()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithMetricCatalogTTL(10*time.Minute))
+	collector.now = func() time.Time { return now }
+
+	metrics, err := collector.metricCatalog()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	collector.updateMetricNames(metrics)
+	collector.getOrCreateMetricDesc(&sonarqube.Metric{Key: "legacy_metric"})
+
+	// Within the TTL, the cached catalog is used
+	now = now.Add(5 * time.Minute)
+	if _, err := collector.metricCatalog(); err != nil || server.requestCount() != 1 {
+		t.Fatalf("Expected the cached catalog after 1 request, got %d requests (%v)", server.requestCount(), err)
+	}
+
+	// A plugin is installed and another metric removed: once the TTL expires, the cached catalog
+	// is returned while the new one is fetched in the background
+	server.setMetrics(sonarqube.Metric{Key: "bugs"}, sonarqube.Metric{Key: "custom_plugin_metric"})
+	now = now.Add(10 * time.Minute)
+
+	metrics, _ = collector.metricCatalog()
+	if len(metrics) != 2 || metrics[1].Key != "legacy_metric" {
+		t.Errorf("Expected the cached catalog during the refresh, got: %v", metrics)
+	}
+	collector.catalogRefresh.Wait()
+
+	metrics, _ = collector.metricCatalog()
+	if len(metrics) != 2 || metrics[1].Key != "custom_plugin_metric" {
+		t.Errorf("Expected the refreshed catalog, got: %v", metrics)
+	}
+	if server.requestCount() != 2 {
+		t.Errorf("Expected 2 requests, got: %d", server.requestCount())
+	}
+
+	collector.updateMetricNames(metrics)
+	if _, exists := collector.metricDescs["legacy_metric"]; exists {
+		t.Error("Expected descriptor of removed metric to be evicted")
+	}
+}
+
+func TestMetricCatalog_RefreshError(t *testing.T) {
+	server := newCatalogServer(sonarqube.Metric{Key: "bugs"})
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithMetricCatalogTTL(time.Minute))
+	collector.now = func() time.Time { return now }
+
+	if _, err := collector.metricCatalog(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// SonarQube becomes unreachable: the cached catalog is kept
+	server.Close()
+	now = now.Add(time.Hour)
+	collector.metricCatalog()
+	collector.catalogRefresh.Wait()
+
+	metrics, err := collector.metricCatalog()
+	if err != nil || len(metrics) != 1 {
+		t.Errorf("Expected the cached catalog, got: %v (%v)", metrics, err)
+	}
+	collector.catalogRefresh.Wait()
+}
+
+func TestDiffMetricCatalogs(t *testing.T) {
+	previous := []sonarqube.Metric{{Key: "bugs"}, {Key: "coverage"}, {Key: "legacy_metric"}}
+	current := []sonarqube.Metric{{Key: "bugs"}, {Key: "coverage"}, {Key: "plugin_a"}, {Key: "plugin_b"}}
+
+	added, removed := diffMetricCatalogs(previous, current)
+	if added != 2 || removed != 1 {
+		t.Errorf("Expected 2 added and 1 removed, got: %d added and %d removed", added, removed)
+	}
+}
@@ -1,11 +1,18 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/notify"
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -15,12 +22,150 @@ type Collector struct {
 	client      *sonarqube.Client
 	projectInfo *prometheus.Desc
 	metricDescs map[string]*prometheus.Desc
+	metricNames map[string]string
+	renamed     string
+	complete    bool
 	mu          sync.RWMutex
+	apiErrors   *prometheus.CounterVec
+
+	// Metric catalog cache
+	catalogSize       *prometheus.Desc
+	catalogTTL        time.Duration
+	catalogMu         sync.Mutex
+	catalog           []sonarqube.Metric
+	catalogFetched    time.Time
+	catalogRefreshing bool
+	catalogRefresh    sync.WaitGroup
+
+	// Optional incremental refresh of measures
+	incrementalRefresh bool
+	resyncInterval     time.Duration
+	lastResync         time.Time
+	measureCache       map[string]cachedMeasures
+	measureCacheKeys   string
+
+	// Optional analysis webhooks
+	analysisWebhooks bool
+	analyses         *prometheus.CounterVec
+	webhookRefresh   sync.WaitGroup
+	webhookMu        sync.Mutex
+	webhookPending   map[string]string
+	webhookSlots     chan struct{}
+	projectCache     []sonarqube.Component
+
+	// Analysis freshness
+	lastAnalysis   *prometheus.Desc
+	neverAnalyzed  *prometheus.Desc
+	staleProject   *prometheus.Desc
+	staleThreshold time.Duration
+	now            func() time.Time
+
+	// Project tags
+	projectTag   *prometheus.Desc
+	tagPrefixes  []string
+	tagLabelKeys []string
+
+	// Optional analysis history
+	analysisHistory bool
+	analysisInfo    *prometheus.Desc
+	analysisEvents  *prometheus.Desc
+
+	// Optional security hotspots
+	hotspots              bool
+	hotspotsByStatus      *prometheus.Desc
+	hotspotsByResolution  *prometheus.Desc
+	hotspotsByProbability *prometheus.Desc
+	hotspotsReviewed      *prometheus.Desc
+	probabilityCache      map[string]cachedProbabilities
+
+	// Optional security reports
+	securityStandards       []string
+	securityVulnerabilities *prometheus.Desc
+	securityHotspots        *prometheus.Desc
+
+	// Optional quality profiles
+	qualityProfiles        bool
+	standardProfiles       map[string]bool
+	profileActiveRules     *prometheus.Desc
+	profileDeprecatedRules *prometheus.Desc
+	profileDefault         *prometheus.Desc
+	profileBuiltIn         *prometheus.Desc
+	projectProfile         *prometheus.Desc
+	projectProfileDrift    *prometheus.Desc
+
+	// Optional license
+	license             bool
+	licenseSkipped      bool
+	licenseRetryAt      time.Time
+	licenseUsageRetryAt time.Time
+	licenseInfo         *prometheus.Desc
+	licenseMaxLoc       *prometheus.Desc
+	licenseLoc          *prometheus.Desc
+	licenseUsage        *prometheus.Desc
+	licenseExpiration   *prometheus.Desc
+	licenseProjectLoc   *prometheus.Desc
+
+	// Optional token monitoring
+	tokenMonitoring bool
+	tokenName       string
+	tokenNotFound   bool
+	tokenValid      *prometheus.Desc
+	tokenExpiration *prometheus.Desc
+
+	// Quality gate status
+	qualityGateStatus *prometheus.Desc
+
+	// Optional analysis timestamps of measures
+	analysisTimestamps bool
+	timestampWindow    time.Duration
+	lastCollection     time.Time
+	stampedAnalyses    map[string]time.Time
+
+	// Optional quality gate transition notifications
+	notifier     *notify.Notifier
+	gateStatuses map[string]string
+	notifyDone   sync.WaitGroup
+	notifySlots  chan struct{}
+
+	// Optional snapshot, served until the first refresh after a restart
+	snapshotPath     string
+	snapshotStale    *prometheus.Desc
+	snapshotMu       sync.Mutex
+	staleMetrics     []prometheus.Metric
+	warmingUp        bool
+	warmUpDone       sync.WaitGroup
+	snapshotMeasures map[string][]sonarqube.Measure
+
+	// Optional rating state-set series
+	ratingLabels bool
+	ratingDesc   *prometheus.Desc
 }
 
+// Option configures optional behaviour of the Collector
+type Option func(*Collector)
+
+// WithRatingLabels enables the sonarqube_rating state-set series, which expose
+// RATING metrics with their letter (A to E) as a label
+func WithRatingLabels(enabled bool) Option {
+	return func(c *Collector) {
+		c.ratingLabels = enabled
+	}
+}
+
+// WithStaleThreshold enables the sonarqube_project_stale gauge, flagging projects whose
+// last analysis is older than the threshold. A zero threshold disables it.
+func WithStaleThreshold(threshold time.Duration) Option {
+	return func(c *Collector) {
+		c.staleThreshold = threshold
+	}
+}
+
+// ratingLetters maps SonarQube rating values (1 to 5) to their letters
+var ratingLetters = []string{"A", "B", "C", "D", "E"}
+
 // NewCollector creates a new Prometheus collector for SonarQube metrics
-func NewCollector(client *sonarqube.Client) *Collector {
-	return &Collector{
+func NewCollector(client *sonarqube.Client, opts ...Option) *Collector {
+	c := &Collector{
 		client: client,
 		projectInfo: prometheus.NewDesc(
 			"sonarqube_project_info",
@@ -29,61 +174,505 @@ func NewCollector(client *sonarqube.Client) *Collector {
 			nil,
 		),
 		metricDescs: make(map[string]*prometheus.Desc),
+		catalogSize: prometheus.NewDesc(
+			"sonarqube_metric_catalog_size",
+			"Number of metrics in the SonarQube metric catalog",
+			nil,
+			nil,
+		),
+		apiErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sonarqube_api_errors_total",
+				Help: "Number of failed requests to the SonarQube Web API, by endpoint and reason",
+			},
+			[]string{"endpoint", "reason"},
+		),
+		analyses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sonarqube_analyses_total",
+				Help: "Number of analyses notified by SonarQube webhooks, by project and status",
+			},
+			[]string{"project_key", "status"},
+		),
+		lastAnalysis: prometheus.NewDesc(
+			"sonarqube_project_last_analysis_timestamp_seconds",
+			"Timestamp of the last analysis of SonarQube projects",
+			[]string{"project_key", "project_name"},
+			nil,
+		),
+		neverAnalyzed: prometheus.NewDesc(
+			"sonarqube_projects_never_analyzed",
+			"Number of SonarQube projects that have never been analyzed",
+			nil,
+			nil,
+		),
+		staleProject: prometheus.NewDesc(
+			"sonarqube_project_stale",
+			"Whether the last analysis of SonarQube projects is older than the stale threshold",
+			[]string{"project_key", "project_name"},
+			nil,
+		),
+		projectTag: prometheus.NewDesc(
+			"sonarqube_project_tag",
+			"Tags of SonarQube projects",
+			[]string{"project_key", "tag"},
+			nil,
+		),
+		now: time.Now,
+		analysisInfo: prometheus.NewDesc(
+			"sonarqube_project_analysis_info",
+			"Version, SCM revision and CI of the latest analysis of SonarQube projects",
+			[]string{"project_key", "project_name", "project_version", "revision", "detected_ci"},
+			nil,
+		),
+		analysisEvents: prometheus.NewDesc(
+			"sonarqube_project_analysis_events",
+			"Number of analyses of SonarQube projects with an event of the category, as kept in the history",
+			[]string{"project_key", "category"},
+			nil,
+		),
+		hotspotsByStatus: prometheus.NewDesc(
+			"sonarqube_hotspots_by_status",
+			"Number of security hotspots of SonarQube projects by review status",
+			[]string{"project_key", "project_name", "status"},
+			nil,
+		),
+		hotspotsByResolution: prometheus.NewDesc(
+			"sonarqube_hotspots_by_resolution",
+			"Number of reviewed security hotspots of SonarQube projects by resolution",
+			[]string{"project_key", "project_name", "resolution"},
+			nil,
+		),
+		hotspotsByProbability: prometheus.NewDesc(
+			"sonarqube_hotspots_by_vulnerability_probability",
+			"Number of security hotspots of SonarQube projects by vulnerability probability",
+			[]string{"project_key", "project_name", "vulnerability_probability"},
+			nil,
+		),
+		hotspotsReviewed: prometheus.NewDesc(
+			"sonarqube_hotspots_reviewed_percent",
+			"Percentage of reviewed security hotspots of SonarQube projects",
+			[]string{"project_key", "project_name"},
+			nil,
+		),
+		securityVulnerabilities: prometheus.NewDesc(
+			"sonarqube_security_report_vulnerabilities",
+			"Number of vulnerabilities of SonarQube projects by security standard category",
+			[]string{"project_key", "project_name", "standard", "category"},
+			nil,
+		),
+		securityHotspots: prometheus.NewDesc(
+			"sonarqube_security_report_hotspots",
+			"Number of security hotspots of SonarQube projects by security standard category and review status",
+			[]string{"project_key", "project_name", "standard", "category", "status"},
+			nil,
+		),
+		profileActiveRules: prometheus.NewDesc(
+			"sonarqube_quality_profile_active_rules",
+			"Number of active rules of SonarQube quality profiles",
+			[]string{"profile_key", "profile_name", "language"},
+			nil,
+		),
+		profileDeprecatedRules: prometheus.NewDesc(
+			"sonarqube_quality_profile_deprecated_rules",
+			"Number of active deprecated rules of SonarQube quality profiles",
+			[]string{"profile_key", "profile_name", "language"},
+			nil,
+		),
+		profileDefault: prometheus.NewDesc(
+			"sonarqube_quality_profile_default",
+			"Whether SonarQube quality profiles are the default profile of their language",
+			[]string{"profile_key", "profile_name", "language"},
+			nil,
+		),
+		profileBuiltIn: prometheus.NewDesc(
+			"sonarqube_quality_profile_built_in",
+			"Whether SonarQube quality profiles are built-in",
+			[]string{"profile_key", "profile_name", "language"},
+			nil,
+		),
+		projectProfile: prometheus.NewDesc(
+			"sonarqube_project_quality_profile",
+			"Quality profiles used by SonarQube projects, per language",
+			[]string{"project_key", "project_name", "language", "profile_key", "profile_name"},
+			nil,
+		),
+		projectProfileDrift: prometheus.NewDesc(
+			"sonarqube_project_quality_profile_drift",
+			"Whether the quality profile of SonarQube projects does not inherit from a standard profile",
+			[]string{"project_key", "project_name", "language", "profile_name"},
+			nil,
+		),
+		licenseInfo: prometheus.NewDesc(
+			"sonarqube_license_info",
+			"Edition and type of the SonarQube license",
+			[]string{"edition", "type"},
+			nil,
+		),
+		licenseMaxLoc: prometheus.NewDesc(
+			"sonarqube_license_max_loc",
+			"Maximum number of lines of code allowed by the SonarQube license",
+			nil,
+			nil,
+		),
+		licenseLoc: prometheus.NewDesc(
+			"sonarqube_license_loc",
+			"Number of lines of code consumed from the SonarQube license",
+			nil,
+			nil,
+		),
+		licenseUsage: prometheus.NewDesc(
+			"sonarqube_license_loc_usage_ratio",
+			"Ratio of the lines of code allowed by the SonarQube license that are consumed",
+			nil,
+			nil,
+		),
+		licenseExpiration: prometheus.NewDesc(
+			"sonarqube_license_expiration_timestamp_seconds",
+			"Expiration timestamp of the SonarQube license",
+			nil,
+			nil,
+		),
+		licenseProjectLoc: prometheus.NewDesc(
+			"sonarqube_license_project_loc",
+			"Number of lines of code of SonarQube projects counted against the license",
+			[]string{"project_key", "project_name"},
+			nil,
+		),
+		qualityGateStatus: prometheus.NewDesc(
+			"sonarqube_project_quality_gate_status",
+			"Quality gate status of SonarQube projects as a state set, 1 for the current status and 0 otherwise",
+			[]string{"project_key", "project_name", "status"},
+			nil,
+		),
+		snapshotStale: prometheus.NewDesc(
+			"sonarqube_exporter_snapshot_stale",
+			"Whether the metrics are served from the snapshot loaded on startup, until the first refresh completes",
+			nil,
+			nil,
+		),
+		tokenValid: prometheus.NewDesc(
+			"sonarqube_exporter_token_valid",
+			"Whether the token of the exporter is valid",
+			nil,
+			nil,
+		),
+		tokenExpiration: prometheus.NewDesc(
+			"sonarqube_exporter_token_expiration_timestamp_seconds",
+			"Expiration timestamp of the token of the exporter",
+			[]string{"token_name"},
+			nil,
+		),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	c.ratingDesc = prometheus.NewDesc(
+		"sonarqube_rating",
+		"SonarQube rating metrics as a state set, 1 for the current rating letter and 0 otherwise",
+		append([]string{"project_key", "project_name", "metric", "rating"}, c.tagLabelKeys...),
+		nil,
+	)
+
+	return c
 }
 
 // Describe sends the descriptors of each metric to the provided channel
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.projectInfo
+	ch <- c.catalogSize
+	c.apiErrors.Describe(ch)
+	if c.analysisWebhooks {
+		c.analyses.Describe(ch)
+	}
+	ch <- c.lastAnalysis
+	ch <- c.neverAnalyzed
+	ch <- c.projectTag
+	ch <- c.qualityGateStatus
+	if c.snapshotPath != "" {
+		ch <- c.snapshotStale
+	}
+	if c.staleThreshold > 0 {
+		ch <- c.staleProject
+	}
+	if c.analysisHistory {
+		ch <- c.analysisInfo
+		ch <- c.analysisEvents
+	}
+	if c.hotspots {
+		ch <- c.hotspotsByStatus
+		ch <- c.hotspotsByResolution
+		ch <- c.hotspotsByProbability
+		ch <- c.hotspotsReviewed
+	}
+	if len(c.securityStandards) > 0 {
+		ch <- c.securityVulnerabilities
+		ch <- c.securityHotspots
+	}
+	if c.qualityProfiles {
+		ch <- c.profileActiveRules
+		ch <- c.profileDeprecatedRules
+		ch <- c.profileDefault
+		ch <- c.profileBuiltIn
+		ch <- c.projectProfile
+		if len(c.standardProfiles) > 0 {
+			ch <- c.projectProfileDrift
+		}
+	}
+	if c.license {
+		ch <- c.licenseInfo
+		ch <- c.licenseMaxLoc
+		ch <- c.licenseLoc
+		ch <- c.licenseUsage
+		ch <- c.licenseExpiration
+		ch <- c.licenseProjectLoc
+	}
+	if c.tokenMonitoring {
+		ch <- c.tokenValid
+		ch <- c.tokenExpiration
+	}
+	if c.ratingLabels {
+		ch <- c.ratingDesc
+	}
 }
 
 // Collect is called by the Prometheus registry when collecting metrics
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// Until the first refresh completes, serve the snapshot loaded on startup
+	if c.collectStaleSnapshot(ch) {
+		return
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.collectCounters(ch)
+
+	c.complete = c.collect(ch)
+
+	if c.snapshotPath != "" {
+		ch <- prometheus.MustNewConstMetric(c.snapshotStale, prometheus.GaugeValue, 0)
+	}
+}
+
+// Complete reports whether the last collection completed, i.e. fetched the metric catalog and
+// the projects. Measures of some projects may still be missing.
+func (c *Collector) Complete() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.complete
+}
+
+// Close waits for the work the collector runs in the background, i.e. the warm-up collection, the
+// webhook and metric catalog refreshes and the quality gate notifications, or until the context is
+// done. Collections must not be started anymore, e.g. once the HTTP server is shut down.
+func (c *Collector) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		// Refreshes and collections may start the later work, so they are waited for first
+		c.webhookRefresh.Wait()
+		c.warmUpDone.Wait()
+		c.catalogRefresh.Wait()
+		c.notifyDone.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background work of the collector was interrupted: %w", ctx.Err())
+	}
+}
+
+// collectCounters exports the counters updated outside of the collections
+func (c *Collector) collectCounters(ch chan<- prometheus.Metric) {
+	c.apiErrors.Collect(ch)
+	if c.analysisWebhooks {
+		c.analyses.Collect(ch)
+	}
+}
+
+// collect fetches the data from SonarQube and exports it. It returns false if the metric catalog
+// or the projects could not be fetched. Callers must hold the mu lock.
+func (c *Collector) collect(ch chan<- prometheus.Metric) bool {
+	// Instance-wide metrics don't depend on the metric catalog
+	if c.tokenMonitoring {
+		c.collectToken(ch)
+	}
+	if c.license {
+		c.collectLicense(ch)
+	}
 
 	// Fetch available metrics from SonarQube
-	metrics, err := c.client.GetMetrics()
+	metrics, err := c.metricCatalog()
 	if err != nil {
 		log.Printf("Error fetching metrics: %v", err)
-		return
+		c.recordError(err)
+		return false
 	}
+	c.exportMetricCatalogSize(ch, metrics)
 
-	// Build list of numeric metric keys to fetch
-	numericMetricKeys := c.getNumericMetricKeys(metrics)
+	// Build list of numeric metric keys to fetch, evicting the descriptors of removed metrics
+	metricKeys := withQualityGateKey(c.getNumericMetricKeys(metrics), metrics)
+	c.updateMetricNames(metrics)
 
 	// Fetch all projects
-	projects, err := c.client.GetProjects()
+	projects, err := c.getProjects()
 	if err != nil {
 		log.Printf("Error fetching projects: %v", err)
-		return
+		c.recordError(err)
+
+		// A truncated list still holds the projects found, which are exported
+		if !errors.Is(err, sonarqube.ErrProjectListTruncated) {
+			return false
+		}
+	}
+
+	if c.incrementalRefresh {
+		c.prepareMeasureCache(projects, metricKeys)
+	}
+	if c.hotspots {
+		c.pruneProbabilityCache(projects)
+	}
+
+	// Export the quality profile inventory, used to compute profile drift of each project
+	var profiles map[string]sonarqube.QualityProfile
+	if c.qualityProfiles {
+		profiles = c.collectQualityProfiles(ch)
 	}
 
 	// For each project, fetch its measures and expose them
+	snap := newSnapshot(metrics, projects, c.now())
+	stamped := make(map[string]time.Time)
+	neverAnalyzed := 0
 	for _, project := range projects {
-		// Export project info metric
-		ch <- prometheus.MustNewConstMetric(
-			c.projectInfo,
-			prometheus.GaugeValue,
-			1,
-			project.Key,
-			project.Name,
-			project.Qualifier,
-			project.Visibility,
-		)
+		if !c.exportProject(ch, project) {
+			neverAnalyzed++
+		}
+
+		if c.analysisHistory {
+			c.collectAnalyses(ch, project)
+		}
+		if c.hotspots {
+			c.collectHotspots(ch, project)
+		}
+		if len(c.securityStandards) > 0 {
+			c.collectSecurityReports(ch, project)
+		}
+		if c.qualityProfiles {
+			c.collectProjectQualityProfiles(ch, project, profiles)
+		}
 
 		// Fetch measures for this project
-		measures, err := c.client.GetProjectMeasures(project.Key, numericMetricKeys)
+		measures, err := c.projectMeasures(project, metricKeys)
 		if err != nil {
 			log.Printf("Error fetching measures for project %s: %v", project.Key, err)
+			c.recordError(err)
 			continue
 		}
 
-		// Export each measure
-		for _, measure := range measures {
-			c.exportMeasure(ch, project.Key, project.Name, measure, metrics)
+		snap.Measures[project.Key] = measures
+		c.exportMeasures(ch, project, measures, metrics, c.analysisTimestamp(project, snap.TakenAt, stamped))
+	}
+
+	if c.analysisTimestamps {
+		c.lastCollection = snap.TakenAt
+		c.stampedAnalyses = stamped
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.neverAnalyzed,
+		prometheus.GaugeValue,
+		float64(neverAnalyzed),
+	)
+
+	if c.notifier != nil {
+		c.notifyQualityGateTransitions(snap)
+	}
+	if c.snapshotPath != "" {
+		c.carryOverMeasures(snap)
+		c.saveSnapshot(snap)
+	}
+
+	return true
+}
+
+// exportProject exports the info, last analysis date and tags of a project. It returns false
+// if the project has never been analyzed.
+func (c *Collector) exportProject(ch chan<- prometheus.Metric, project sonarqube.Component) bool {
+	ch <- prometheus.MustNewConstMetric(
+		c.projectInfo,
+		prometheus.GaugeValue,
+		1,
+		project.Key,
+		project.Name,
+		project.Qualifier,
+		project.Visibility,
+	)
+
+	analyzed := c.exportAnalysisDate(ch, project)
+	c.exportTags(ch, project)
+
+	return analyzed
+}
+
+// exportMeasures exports the measures of a project, and its quality gate status. Samples are
+// stamped with the timestamp, unless it is zero.
+func (c *Collector) exportMeasures(ch chan<- prometheus.Metric, project sonarqube.Component, measures []sonarqube.Measure, allMetrics []sonarqube.Metric, timestamp time.Time) {
+	for _, measure := range measures {
+		if measure.Metric == qualityGateMetricKey {
+			c.exportQualityGate(ch, project, measure.Value, timestamp)
+			continue
+		}
+
+		c.exportMeasure(ch, project, measure, allMetrics, timestamp)
+	}
+}
+
+// exportAnalysisDate exports the last analysis timestamp of a project and, if enabled,
+// whether it is stale. It returns false if the project has never been analyzed.
+func (c *Collector) exportAnalysisDate(ch chan<- prometheus.Metric, project sonarqube.Component) bool {
+	if project.AnalysisDate == "" {
+		return false
+	}
+
+	analysisDate, err := sonarqube.ParseDateTime(project.AnalysisDate)
+	if err != nil {
+		log.Printf("Error parsing analysis date for project %s: %v", project.Key, err)
+		return true
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.lastAnalysis,
+		prometheus.GaugeValue,
+		float64(analysisDate.Unix()),
+		project.Key,
+		project.Name,
+	)
+
+	if c.staleThreshold > 0 {
+		stale := 0.0
+		if c.now().Sub(analysisDate) > c.staleThreshold {
+			stale = 1
 		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.staleProject,
+			prometheus.GaugeValue,
+			stale,
+			project.Key,
+			project.Name,
+		)
 	}
+
+	return true
+}
+
+// recordError counts a failed request to the SonarQube Web API
+func (c *Collector) recordError(err error) {
+	c.apiErrors.WithLabelValues(sonarqube.ErrorEndpoint(err), sonarqube.ErrorReason(err)).Inc()
 }
 
 // getNumericMetricKeys returns the keys of metrics that have numeric values
@@ -107,8 +696,8 @@ func (c *Collector) getNumericMetricKeys(metrics []sonarqube.Metric) []string {
 	return keys
 }
 
-// exportMeasure exports a single measure as a Prometheus metric
-func (c *Collector) exportMeasure(ch chan<- prometheus.Metric, projectKey, projectName string, measure sonarqube.Measure, allMetrics []sonarqube.Metric) {
+// exportMeasure exports a single measure as a Prometheus metric, stamped with the timestamp unless it is zero
+func (c *Collector) exportMeasure(ch chan<- prometheus.Metric, project sonarqube.Component, measure sonarqube.Measure, allMetrics []sonarqube.Metric, timestamp time.Time) {
 	// Find the metric definition
 	var metricDef *sonarqube.Metric
 	for i := range allMetrics {
@@ -133,13 +722,48 @@ func (c *Collector) exportMeasure(ch chan<- prometheus.Metric, projectKey, proje
 	desc := c.getOrCreateMetricDesc(metricDef)
 
 	// Export the metric
-	ch <- prometheus.MustNewConstMetric(
+	tagValues := c.tagLabelValues(project)
+	ch <- withTimestamp(prometheus.MustNewConstMetric(
 		desc,
 		prometheus.GaugeValue,
 		value,
-		projectKey,
-		projectName,
-	)
+		append([]string{project.Key, project.Name}, tagValues...)...,
+	), timestamp)
+
+	if c.ratingLabels && metricDef.Type == "RATING" {
+		c.exportRating(ch, project, metricDef.Key, value, tagValues, timestamp)
+	}
+}
+
+// exportRating exports a rating value as a state set, one series per rating letter
+func (c *Collector) exportRating(ch chan<- prometheus.Metric, project sonarqube.Component, metricKey string, value float64, tagValues []string, timestamp time.Time) {
+	current, ok := ratingLetter(value)
+	if !ok {
+		return
+	}
+
+	for _, letter := range ratingLetters {
+		state := 0.0
+		if letter == current {
+			state = 1
+		}
+
+		ch <- withTimestamp(prometheus.MustNewConstMetric(
+			c.ratingDesc,
+			prometheus.GaugeValue,
+			state,
+			append([]string{project.Key, project.Name, metricKey, letter}, tagValues...)...,
+		), timestamp)
+	}
+}
+
+// ratingLetter returns the letter of a rating value, if it is a valid rating
+func ratingLetter(value float64) (string, bool) {
+	index := int(value) - 1
+	if value != math.Trunc(value) || index < 0 || index >= len(ratingLetters) {
+		return "", false
+	}
+	return ratingLetters[index], true
 }
 
 // getOrCreateMetricDesc gets or creates a Prometheus metric descriptor
@@ -148,13 +772,10 @@ func (c *Collector) getOrCreateMetricDesc(metric *sonarqube.Metric) *prometheus.
 		return desc
 	}
 
-	// Sanitize metric name for Prometheus
-	metricName := "sonarqube_" + sanitizeMetricName(metric.Key)
-
 	desc := prometheus.NewDesc(
-		metricName,
+		c.metricName(metric.Key),
 		metric.Description,
-		[]string{"project_key", "project_name"},
+		append([]string{"project_key", "project_name"}, c.tagLabelKeys...),
 		prometheus.Labels{"domain": metric.Domain},
 	)
 
@@ -162,13 +783,159 @@ func (c *Collector) getOrCreateMetricDesc(metric *sonarqube.Metric) *prometheus.
 	return desc
 }
 
-// sanitizeMetricName converts a SonarQube metric key to a valid Prometheus metric name
+// metricName returns the Prometheus metric name of a SonarQube metric, the collision-free name
+// computed from the catalog if any
+func (c *Collector) metricName(key string) string {
+	if name, exists := c.metricNames[key]; exists {
+		return name
+	}
+	return metricNamePrefix + sanitizeMetricName(key)
+}
+
+// metricNamePrefix is prepended to every metric exported from a SonarQube measure
+const metricNamePrefix = "sonarqube_"
+
+// sanitizeMetricName converts a SonarQube metric key to a valid Prometheus metric name suffix.
+// Every character outside [a-z0-9_] is replaced with an underscore, including colons, which
+// Prometheus reserves for recording rules.
 func sanitizeMetricName(key string) string {
-	// Replace invalid characters with underscores
-	key = strings.ReplaceAll(key, "-", "_")
-	key = strings.ReplaceAll(key, ".", "_")
-	key = strings.ToLower(key)
-	return key
+	var b strings.Builder
+	for _, r := range strings.ToLower(key) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// builtInMetricNames are the names of the metrics exported by the collector itself, which metrics
+// of the catalog must not take. Counters are also reserved without their _total suffix, which the
+// OpenMetrics format drops from the metric family name.
+var builtInMetricNames = []string{
+	"sonarqube_analyses",
+	"sonarqube_analyses_total",
+	"sonarqube_api_errors",
+	"sonarqube_api_errors_total",
+	"sonarqube_exporter_snapshot_stale",
+	"sonarqube_exporter_token_expiration_timestamp_seconds",
+	"sonarqube_exporter_token_valid",
+	"sonarqube_hotspots_by_resolution",
+	"sonarqube_hotspots_by_status",
+	"sonarqube_hotspots_by_vulnerability_probability",
+	"sonarqube_hotspots_reviewed_percent",
+	"sonarqube_license_expiration_timestamp_seconds",
+	"sonarqube_license_info",
+	"sonarqube_license_loc",
+	"sonarqube_license_loc_usage_ratio",
+	"sonarqube_license_max_loc",
+	"sonarqube_license_project_loc",
+	"sonarqube_metric_catalog_size",
+	"sonarqube_project_analysis_events",
+	"sonarqube_project_analysis_info",
+	"sonarqube_project_info",
+	"sonarqube_project_last_analysis_timestamp_seconds",
+	"sonarqube_project_quality_gate_status",
+	"sonarqube_project_quality_profile",
+	"sonarqube_project_quality_profile_drift",
+	"sonarqube_project_stale",
+	"sonarqube_project_tag",
+	"sonarqube_projects_never_analyzed",
+	"sonarqube_quality_profile_active_rules",
+	"sonarqube_quality_profile_built_in",
+	"sonarqube_quality_profile_default",
+	"sonarqube_quality_profile_deprecated_rules",
+	"sonarqube_rating",
+	"sonarqube_security_report_hotspots",
+	"sonarqube_security_report_vulnerabilities",
+}
+
+// buildMetricNames assigns a unique, valid Prometheus metric name to each metric of the catalog.
+// Keys still in the catalog keep their previous name, so that a metric added later doesn't rename
+// the series of an existing one. Keys that are already valid names keep them, unless a built-in or
+// a previously assigned metric has that name; the other keys are sanitized in sorted order and get
+// a numeric suffix when their name is already taken, so that the result is deterministic for a
+// given catalog. It also returns the sorted keys whose name differs from the original key.
+func buildMetricNames(metrics []sonarqube.Metric, previous map[string]string) (map[string]string, []string) {
+	keys := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		keys = append(keys, metric.Key)
+	}
+	sort.Strings(keys)
+
+	names := make(map[string]string, len(keys))
+	taken := make(map[string]bool, len(keys)+len(builtInMetricNames))
+	for _, name := range builtInMetricNames {
+		taken[name] = true
+	}
+
+	for _, key := range keys {
+		if name, exists := previous[key]; exists && !taken[name] {
+			names[key] = name
+			taken[name] = true
+		}
+	}
+
+	// Keys that don't need sanitizing have priority over renamed ones
+	for _, key := range keys {
+		if _, exists := names[key]; exists {
+			continue
+		}
+		if sanitizeMetricName(key) == key && !taken[metricNamePrefix+key] {
+			names[key] = metricNamePrefix + key
+			taken[names[key]] = true
+		}
+	}
+
+	for _, key := range keys {
+		if _, exists := names[key]; exists {
+			continue
+		}
+
+		base := metricNamePrefix + sanitizeMetricName(key)
+		name := base
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+
+		names[key] = name
+		taken[name] = true
+	}
+
+	var renamed []string
+	for _, key := range keys {
+		if names[key] != metricNamePrefix+key {
+			renamed = append(renamed, key)
+		}
+	}
+
+	return names, renamed
+}
+
+// updateMetricNames recomputes the metric names from the catalog, evicting the descriptors of the
+// removed metrics and logging the renamed keys whenever they change
+func (c *Collector) updateMetricNames(metrics []sonarqube.Metric) {
+	names, renamed := buildMetricNames(metrics, c.metricNames)
+
+	for key := range c.metricDescs {
+		if c.metricNames[key] != names[key] {
+			delete(c.metricDescs, key)
+		}
+	}
+	c.metricNames = names
+
+	var mappings []string
+	for _, key := range renamed {
+		mappings = append(mappings, fmt.Sprintf("%s -> %s", key, names[key]))
+	}
+
+	if summary := strings.Join(mappings, ", "); summary != c.renamed {
+		c.renamed = summary
+		if summary != "" {
+			log.Printf("Warning: %d SonarQube metric keys were renamed to valid Prometheus metric names: %s", len(mappings), summary)
+		}
+	}
 }
 
 // parseMetricValue parses a metric value string to float64
@@ -185,8 +952,7 @@ func parseMetricValue(value, metricType string) (float64, error) {
 	case "FLOAT", "PERCENT":
 		return strconv.ParseFloat(value, 64)
 	case "RATING":
-		// Ratings in SonarQube are A=1.0, B=2.0, C=3.0, D=4.0, E=5.0
-		return strconv.ParseFloat(value, 64)
+		return parseRating(value)
 	case "WORK_DUR":
 		// Work duration is in minutes
 		i, err := strconv.ParseInt(value, 10, 64)
@@ -195,3 +961,19 @@ func parseMetricValue(value, metricType string) (float64, error) {
 		return 0, nil
 	}
 }
+
+// parseRating parses a rating value, rounding it to the nearest rating.
+// Ratings in SonarQube are A=1.0, B=2.0, C=3.0, D=4.0, E=5.0
+func parseRating(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	rating := math.Round(f)
+	if math.IsNaN(rating) || rating < 1 || rating > float64(len(ratingLetters)) {
+		return 0, fmt.Errorf("rating %s out of range [1, %d]", value, len(ratingLetters))
+	}
+
+	return rating, nil
+}
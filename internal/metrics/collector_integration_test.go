@@ -8,6 +8,7 @@ import (
 
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // TestCollect_Integration tests the full Collect workflow with a mock SonarQube server
@@ -52,11 +53,12 @@ func TestCollect_Integration(t *testing.T) {
 				},
 				Components: []sonarqube.Component{
 					{
-						Key:        "project1",
-						Name:       "Project 1",
-						Qualifier:  "TRK",
-						Visibility: "private",
-						Tags:       []string{"tag1"},
+						Key:          "project1",
+						Name:         "Project 1",
+						Qualifier:    "TRK",
+						Visibility:   "private",
+						AnalysisDate: "2024-01-15T10:30:00+0100",
+						Tags:         []string{"tag1"},
 					},
 					{
 						Key:        "project2",
@@ -121,11 +123,19 @@ func TestCollect_Integration(t *testing.T) {
 	// - 2 project_info metrics (one for each project)
 	// - 2 bugs metrics (one for each project)
 	// - 2 coverage metrics (one for each project)
-	// Total: 6 metrics
-	expectedCount := 6
+	// - 1 last analysis timestamp metric (project2 was never analyzed)
+	// - 1 never analyzed projects metric
+	// - 1 project tag metric (project1 is tagged tag1)
+	// - 1 metric catalog size metric
+	// Total: 10 metrics
+	expectedCount := 10
 	if count != expectedCount {
 		t.Errorf("Expected %d metrics, got: %d", expectedCount, count)
 	}
+
+	if !collector.Complete() {
+		t.Error("Expected the collection to be complete")
+	}
 }
 
 // TestCollect_MetricsError tests Collect when fetching metrics fails
@@ -149,14 +159,32 @@ func TestCollect_MetricsError(t *testing.T) {
 		close(ch)
 	}()
 
-	// Should not collect any metrics when fetching metrics fails
+	// Should only collect the API error when fetching metrics fails
 	count := 0
-	for range ch {
+	for m := range ch {
 		count++
+
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Failed to write metric: %v", err)
+		}
+
+		labels := map[string]string{}
+		for _, label := range pb.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		if labels["endpoint"] != "/api/metrics/search" || labels["reason"] != "unauthorized" || pb.GetCounter().GetValue() != 1 {
+			t.Errorf("Expected 1 unauthorized error on /api/metrics/search, got: %v %v", labels, pb.GetCounter().GetValue())
+		}
 	}
 
-	if count != 0 {
-		t.Errorf("Expected 0 metrics when metrics fetch fails, got: %d", count)
+	if count != 1 {
+		t.Errorf("Expected 1 metric (api_errors_total) when metrics fetch fails, got: %d", count)
+	}
+
+	if collector.Complete() {
+		t.Error("Expected the collection to be incomplete when metrics fetch fails")
 	}
 }
 
@@ -200,9 +228,13 @@ func TestCollect_ProjectsError(t *testing.T) {
 		count++
 	}
 
-	// Should not collect any metrics when fetching projects fails
-	if count != 0 {
-		t.Errorf("Expected 0 metrics when projects fetch fails, got: %d", count)
+	// Should only collect the metric catalog size and the API error when fetching projects fails
+	if count != 2 {
+		t.Errorf("Expected 2 metrics (metric_catalog_size and api_errors_total) when projects fetch fails, got: %d", count)
+	}
+
+	if collector.Complete() {
+		t.Error("Expected the collection to be incomplete when projects fetch fails")
 	}
 }
 
@@ -271,8 +303,93 @@ func TestCollect_MeasuresError(t *testing.T) {
 	}
 
 	// Should still export project_info metric even if measures fail
-	// Expected: 1 project_info metric
-	if count != 1 {
-		t.Errorf("Expected 1 metric (project_info), got: %d", count)
+	// Expected: 1 metric catalog size, 1 project_info metric, 1 never analyzed projects metric and 1 API error metric
+	if count != 4 {
+		t.Errorf("Expected 4 metrics (metric_catalog_size, project_info, projects_never_analyzed and api_errors_total), got: %d", count)
+	}
+}
+
+// TestCollect_TruncatedProjects tests that the projects found are exported when the project list is truncated
+func TestCollect_TruncatedProjects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{})
+
+		case "/api/components/search_projects":
+			// More projects than the search window, and only one returned
+			response := sonarqube.ComponentsResponse{Paging: sonarqube.Paging{Total: 12000}}
+			if r.URL.Query().Get("p") == "1" {
+				response.Components = []sonarqube.Component{{Key: "project1", Name: "Project 1", Qualifier: "TRK"}}
+			}
+			json.NewEncoder(w).Encode(response)
+
+		default:
+			// Listing the remaining projects requires the Administer System permission
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client)
+
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+
+	count := 0
+	for range ch {
+		count++
+	}
+
+	// Expected: 1 metric catalog size, 1 project_info metric, 1 never analyzed projects metric and 1 API error metric
+	if count != 4 {
+		t.Errorf("Expected 4 metrics (metric_catalog_size, project_info, projects_never_analyzed and api_errors_total), got: %d", count)
+	}
+}
+
+// TestCollect_BuiltInNameCollision tests that a plugin metric named like a built-in metric doesn't
+// fail the scrape
+func TestCollect_BuiltInNameCollision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{{Key: "project_info", Type: "INT", Name: "Plugin metric"}},
+				Total:   1,
+			})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging:     sonarqube.Paging{Total: 1},
+				Components: []sonarqube.Component{{Key: "project1", Name: "Project 1"}},
+			})
+		case "/api/measures/component":
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key:      "project1",
+					Measures: []sonarqube.Measure{{Metric: "project_info", Value: "7"}},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	families := gather(t, NewCollector(client))
+
+	if value := gaugeValue(t, families, "sonarqube_project_info_2"); value != 7 {
+		t.Errorf("Expected the plugin metric to be renamed sonarqube_project_info_2 with value 7, got: %v", value)
+	}
+	if _, exists := families["sonarqube_project_info"]; !exists {
+		t.Error("Expected the built-in sonarqube_project_info metric to be collected")
 	}
 }
@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestSanitizeMetricName(t *testing.T) {
@@ -28,6 +31,18 @@ func TestSanitizeMetricName(t *testing.T) {
 			input:    "sqale.index",
 			expected: "sqale_index",
 		},
+		{
+			input:    "plugin:custom metric",
+			expected: "plugin_custom_metric",
+		},
+		{
+			input:    "1st_metric",
+			expected: "1st_metric",
+		},
+		{
+			input:    "qualité",
+			expected: "qualit_",
+		},
 	}
 
 	for _, tt := range tests {
@@ -40,6 +55,157 @@ func TestSanitizeMetricName(t *testing.T) {
 	}
 }
 
+func TestBuildMetricNames(t *testing.T) {
+	metrics := []sonarqube.Metric{
+		{Key: "a.b"},
+		{Key: "a_b"},
+		{Key: "a-b"},
+		{Key: "1st:metric"},
+		{Key: "bugs"},
+	}
+
+	names, renamed := buildMetricNames(metrics, nil)
+
+	expected := map[string]string{
+		"a_b":        "sonarqube_a_b",
+		"a-b":        "sonarqube_a_b_2",
+		"a.b":        "sonarqube_a_b_3",
+		"1st:metric": "sonarqube_1st_metric",
+		"bugs":       "sonarqube_bugs",
+	}
+	for key, name := range expected {
+		if names[key] != name {
+			t.Errorf("Expected %s to be named '%s', got: '%s'", key, name, names[key])
+		}
+	}
+
+	expectedRenamed := []string{"1st:metric", "a-b", "a.b"}
+	if strings.Join(renamed, ",") != strings.Join(expectedRenamed, ",") {
+		t.Errorf("Expected renamed keys %v, got: %v", expectedRenamed, renamed)
+	}
+
+	// The assignment must not depend on the catalog order
+	reversed := make([]sonarqube.Metric, len(metrics))
+	for i, metric := range metrics {
+		reversed[len(metrics)-1-i] = metric
+	}
+	reversedNames, _ := buildMetricNames(reversed, nil)
+	for key, name := range names {
+		if reversedNames[key] != name {
+			t.Errorf("Expected %s to be named '%s' regardless of order, got: '%s'", key, name, reversedNames[key])
+		}
+	}
+}
+
+func TestBuildMetricNames_BuiltInNames(t *testing.T) {
+	metrics := []sonarqube.Metric{
+		{Key: "project_info"},
+		{Key: "rating"},
+		{Key: "api_errors"},
+		{Key: "project-stale"},
+	}
+
+	names, renamed := buildMetricNames(metrics, nil)
+
+	expected := map[string]string{
+		"project_info":  "sonarqube_project_info_2",
+		"rating":        "sonarqube_rating_2",
+		"api_errors":    "sonarqube_api_errors_2",
+		"project-stale": "sonarqube_project_stale_2",
+	}
+	for key, name := range expected {
+		if names[key] != name {
+			t.Errorf("Expected %s to be named '%s', got: '%s'", key, name, names[key])
+		}
+	}
+
+	if len(renamed) != len(metrics) {
+		t.Errorf("Expected every key to be reported as renamed, got: %v", renamed)
+	}
+}
+
+func TestBuiltInMetricNames(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client,
+		WithRatingLabels(true),
+		WithStaleThreshold(time.Hour),
+		WithAnalysisHistory(true),
+		WithHotspots(true),
+		WithSecurityReports([]string{sonarqube.SecurityStandardOWASPTop102021}),
+		WithQualityProfiles(true, nil),
+		WithLicense(true),
+		WithTokenMonitoring(true, ""),
+		WithAnalysisWebhooks(true),
+		WithSnapshot("snapshot.json"),
+	)
+
+	ch := make(chan *prometheus.Desc, 100)
+	collector.Describe(ch)
+	close(ch)
+
+	reserved := make(map[string]bool, len(builtInMetricNames))
+	for _, name := range builtInMetricNames {
+		reserved[name] = true
+	}
+
+	// Every metric of the collector must be reserved against the metrics of the catalog
+	for desc := range ch {
+		name := strings.SplitN(strings.TrimPrefix(desc.String(), `Desc{fqName: "`), `"`, 2)[0]
+		if !reserved[name] {
+			t.Errorf("Expected built-in metric %s to be reserved", name)
+		}
+	}
+}
+
+func TestBuildMetricNames_KeepsPreviousNames(t *testing.T) {
+	previous, _ := buildMetricNames([]sonarqube.Metric{{Key: "a.b"}, {Key: "bugs"}}, nil)
+
+	// A plugin adds a_b, whose name was given to a.b
+	names, renamed := buildMetricNames([]sonarqube.Metric{{Key: "a.b"}, {Key: "a_b"}, {Key: "bugs"}}, previous)
+
+	expected := map[string]string{
+		"a.b":  "sonarqube_a_b",
+		"a_b":  "sonarqube_a_b_2",
+		"bugs": "sonarqube_bugs",
+	}
+	for key, name := range expected {
+		if names[key] != name {
+			t.Errorf("Expected %s to be named '%s', got: '%s'", key, name, names[key])
+		}
+	}
+
+	expectedRenamed := []string{"a.b", "a_b"}
+	if strings.Join(renamed, ",") != strings.Join(expectedRenamed, ",") {
+		t.Errorf("Expected renamed keys %v, got: %v", expectedRenamed, renamed)
+	}
+}
+
+func TestUpdateMetricNames_KeepsAssignedNames(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client)
+
+	collector.updateMetricNames([]sonarqube.Metric{{Key: "a.b"}})
+	collector.getOrCreateMetricDesc(&sonarqube.Metric{Key: "a.b"})
+
+	// a_b is added later, a.b keeps its name and descriptor
+	collector.updateMetricNames([]sonarqube.Metric{{Key: "a.b"}, {Key: "a_b"}})
+	if _, exists := collector.metricDescs["a.b"]; !exists {
+		t.Error("Expected descriptor of a.b to be kept")
+	}
+	if collector.metricNames["a.b"] != "sonarqube_a_b" {
+		t.Errorf("Expected 'sonarqube_a_b', got: '%s'", collector.metricNames["a.b"])
+	}
+	if collector.metricNames["a_b"] != "sonarqube_a_b_2" {
+		t.Errorf("Expected 'sonarqube_a_b_2', got: '%s'", collector.metricNames["a_b"])
+	}
+
+	// Descriptors of removed metrics are evicted
+	collector.updateMetricNames([]sonarqube.Metric{{Key: "a_b"}})
+	if _, exists := collector.metricDescs["a.b"]; exists {
+		t.Error("Expected descriptor of removed metric to be evicted")
+	}
+}
+
 func TestParseMetricValue(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -76,6 +242,27 @@ func TestParseMetricValue(t *testing.T) {
 			expected:   2.0,
 			shouldErr:  false,
 		},
+		{
+			name:       "round RATING",
+			value:      "2.4",
+			metricType: "RATING",
+			expected:   2.0,
+			shouldErr:  false,
+		},
+		{
+			name:       "RATING above range",
+			value:      "6.0",
+			metricType: "RATING",
+			expected:   0,
+			shouldErr:  true,
+		},
+		{
+			name:       "RATING below range",
+			value:      "0.2",
+			metricType: "RATING",
+			expected:   0,
+			shouldErr:  true,
+		},
 		{
 			name:       "parse MILLISEC",
 			value:      "1000",
@@ -206,8 +393,10 @@ func TestDescribe(t *testing.T) {
 		count++
 	}
 
-	if count != 1 {
-		t.Errorf("Expected 1 descriptor, got: %d", count)
+	// project_info, metric_catalog_size, api_errors_total, last_analysis_timestamp_seconds,
+	// projects_never_analyzed, project_tag and project_quality_gate_status
+	if count != 7 {
+		t.Errorf("Expected 7 descriptors, got: %d", count)
 	}
 }
 
@@ -260,7 +449,7 @@ func TestExportMeasure(t *testing.T) {
 	}
 
 	ch := make(chan prometheus.Metric, 10)
-	collector.exportMeasure(ch, "project1", "Project 1", measure, allMetrics)
+	collector.exportMeasure(ch, sonarqube.Component{Key: "project1", Name: "Project 1"}, measure, allMetrics, time.Time{})
 	close(ch)
 
 	count := 0
@@ -294,7 +483,7 @@ func TestExportMeasure_InvalidMetric(t *testing.T) {
 	}
 
 	ch := make(chan prometheus.Metric, 10)
-	collector.exportMeasure(ch, "project1", "Project 1", measure, allMetrics)
+	collector.exportMeasure(ch, sonarqube.Component{Key: "project1", Name: "Project 1"}, measure, allMetrics, time.Time{})
 	close(ch)
 
 	count := 0
@@ -328,7 +517,7 @@ func TestExportMeasure_InvalidValue(t *testing.T) {
 	}
 
 	ch := make(chan prometheus.Metric, 10)
-	collector.exportMeasure(ch, "project1", "Project 1", measure, allMetrics)
+	collector.exportMeasure(ch, sonarqube.Component{Key: "project1", Name: "Project 1"}, measure, allMetrics, time.Time{})
 	close(ch)
 
 	count := 0
@@ -341,3 +530,148 @@ func TestExportMeasure_InvalidValue(t *testing.T) {
 		t.Errorf("Expected 0 metrics to be exported for invalid value, got: %d", count)
 	}
 }
+
+func TestRatingLetter(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+		ok       bool
+	}{
+		{value: 1, expected: "A", ok: true},
+		{value: 3, expected: "C", ok: true},
+		{value: 5, expected: "E", ok: true},
+		{value: 0, ok: false},
+		{value: 6, ok: false},
+		{value: 2.5, ok: false},
+	}
+
+	for _, tt := range tests {
+		letter, ok := ratingLetter(tt.value)
+		if ok != tt.ok || letter != tt.expected {
+			t.Errorf("ratingLetter(%v): expected (%q, %v), got: (%q, %v)", tt.value, tt.expected, tt.ok, letter, ok)
+		}
+	}
+}
+
+func TestExportMeasure_RatingLabels(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithRatingLabels(true))
+
+	allMetrics := []sonarqube.Metric{
+		{
+			Key:         "security_rating",
+			Type:        "RATING",
+			Name:        "Security Rating",
+			Description: "Security rating",
+			Domain:      "Security",
+		},
+	}
+
+	measure := sonarqube.Measure{
+		Metric: "security_rating",
+		Value:  "2.0",
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.exportMeasure(ch, sonarqube.Component{Key: "project1", Name: "Project 1"}, measure, allMetrics, time.Time{})
+	close(ch)
+
+	// 1 raw gauge + 5 rating states
+	count := 0
+	active := 0
+	for m := range ch {
+		count++
+		if m.Desc() != collector.ratingDesc {
+			continue
+		}
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Failed to write metric: %v", err)
+		}
+		if pb.GetGauge().GetValue() == 1 {
+			active++
+			for _, label := range pb.GetLabel() {
+				if label.GetName() == "rating" && label.GetValue() != "B" {
+					t.Errorf("Expected active rating 'B', got: %s", label.GetValue())
+				}
+			}
+		}
+	}
+
+	if count != 6 {
+		t.Errorf("Expected 6 metrics to be exported, got: %d", count)
+	}
+	if active != 1 {
+		t.Errorf("Expected exactly 1 active rating state, got: %d", active)
+	}
+}
+
+func TestExportAnalysisDate(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithStaleThreshold(24*time.Hour))
+	collector.now = func() time.Time {
+		return time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name          string
+		analysisDate  string
+		expectedCount int
+		expectedStale float64
+		analyzed      bool
+	}{
+		{
+			name:          "recent analysis",
+			analysisDate:  "2024-01-19T12:00:00+0000",
+			expectedCount: 2,
+			expectedStale: 0,
+			analyzed:      true,
+		},
+		{
+			name:          "stale analysis",
+			analysisDate:  "2024-01-15T10:30:00+0100",
+			expectedCount: 2,
+			expectedStale: 1,
+			analyzed:      true,
+		},
+		{
+			name:          "never analyzed",
+			analysisDate:  "",
+			expectedCount: 0,
+			analyzed:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := sonarqube.Component{Key: "project1", Name: "Project 1", AnalysisDate: tt.analysisDate}
+
+			ch := make(chan prometheus.Metric, 10)
+			analyzed := collector.exportAnalysisDate(ch, project)
+			close(ch)
+
+			if analyzed != tt.analyzed {
+				t.Errorf("Expected analyzed to be %v, got: %v", tt.analyzed, analyzed)
+			}
+
+			count := 0
+			for m := range ch {
+				count++
+				if m.Desc() != collector.staleProject {
+					continue
+				}
+				var pb dto.Metric
+				if err := m.Write(&pb); err != nil {
+					t.Fatalf("Failed to write metric: %v", err)
+				}
+				if pb.GetGauge().GetValue() != tt.expectedStale {
+					t.Errorf("Expected stale value %v, got: %v", tt.expectedStale, pb.GetGauge().GetValue())
+				}
+			}
+
+			if count != tt.expectedCount {
+				t.Errorf("Expected %d metrics, got: %d", tt.expectedCount, count)
+			}
+		})
+	}
+}
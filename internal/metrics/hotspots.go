@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Known hotspot statuses, resolutions and vulnerability probabilities, always exported so that
+// counts drop to zero instead of disappearing
+var (
+	hotspotStatuses      = []string{"TO_REVIEW", "REVIEWED"}
+	hotspotResolutions   = []string{"FIXED", "SAFE", "ACKNOWLEDGED"}
+	hotspotProbabilities = []string{"HIGH", "MEDIUM", "LOW"}
+)
+
+// cachedProbabilities holds the hotspot counts of a project by vulnerability probability, listed
+// after its analysis of the given date. The counts are nil when the hotspots exceed the search window.
+type cachedProbabilities struct {
+	analysisDate string
+	counts       map[string]int
+}
+
+// WithHotspots enables the security hotspot review metrics, built from /api/hotspots/search
+func WithHotspots(enabled bool) Option {
+	return func(c *Collector) {
+		c.hotspots = enabled
+		c.probabilityCache = make(map[string]cachedProbabilities)
+	}
+}
+
+// collectHotspots exports the security hotspot counts and review percentage of a project
+func (c *Collector) collectHotspots(ch chan<- prometheus.Metric, project sonarqube.Component) {
+	// Status and resolution counts come from the totals of single-result searches, so they stay
+	// exact however many hotspots the project has
+	byStatus, err := c.countHotspots(project.Key, hotspotStatuses, func(status string) (int, error) {
+		return c.client.CountProjectHotspots(project.Key, status, "")
+	})
+	if err != nil {
+		return
+	}
+	byResolution, err := c.countHotspots(project.Key, hotspotResolutions, func(resolution string) (int, error) {
+		return c.client.CountProjectHotspots(project.Key, "REVIEWED", resolution)
+	})
+	if err != nil {
+		return
+	}
+
+	c.exportCounts(ch, c.hotspotsByStatus, project, byStatus)
+	c.exportCounts(ch, c.hotspotsByResolution, project, byResolution)
+
+	// Like SonarQube, consider a project without hotspots fully reviewed
+	reviewed := 100.0
+	if total := byStatus["TO_REVIEW"] + byStatus["REVIEWED"]; total > 0 {
+		reviewed = float64(byStatus["REVIEWED"]) / float64(total) * 100
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.hotspotsReviewed,
+		prometheus.GaugeValue,
+		reviewed,
+		project.Key,
+		project.Name,
+	)
+
+	if byProbability := c.probabilityCounts(project); byProbability != nil {
+		c.exportCounts(ch, c.hotspotsByProbability, project, byProbability)
+	}
+}
+
+// probabilityCounts returns the hotspot counts of a project by vulnerability probability. The
+// probability cannot be searched on, so the hotspots are listed, but only after an analysis: reviews
+// don't add or close hotspots, and the probability comes from their rule. Beyond the search window
+// the counts would be partial, and nil is returned.
+func (c *Collector) probabilityCounts(project sonarqube.Component) map[string]int {
+	if cached, exists := c.probabilityCache[project.Key]; exists && sameAnalysisDate(cached.analysisDate, project.AnalysisDate) {
+		return cached.counts
+	}
+
+	hotspots, err := c.client.GetProjectHotspots(project.Key)
+	if err != nil {
+		log.Printf("Error fetching hotspots for project %s: %v", project.Key, err)
+		c.recordError(err)
+
+		// A truncated list is not listed again before the next analysis
+		if errors.Is(err, sonarqube.ErrHotspotListTruncated) {
+			c.probabilityCache[project.Key] = cachedProbabilities{analysisDate: project.AnalysisDate}
+		}
+		return nil
+	}
+
+	counts := zeroCounts(hotspotProbabilities)
+	for _, hotspot := range hotspots {
+		if hotspot.VulnerabilityProbability != "" {
+			counts[hotspot.VulnerabilityProbability]++
+		}
+	}
+
+	c.probabilityCache[project.Key] = cachedProbabilities{analysisDate: project.AnalysisDate, counts: counts}
+	return counts
+}
+
+// pruneProbabilityCache drops the cached hotspot counts of deleted projects
+func (c *Collector) pruneProbabilityCache(projects []sonarqube.Component) {
+	current := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		current[project.Key] = true
+	}
+	for key := range c.probabilityCache {
+		if !current[key] {
+			delete(c.probabilityCache, key)
+		}
+	}
+}
+
+// countHotspots counts the hotspots of a project for each of the values, logging and recording
+// the first error
+func (c *Collector) countHotspots(projectKey string, values []string, count func(string) (int, error)) (map[string]int, error) {
+	counts := make(map[string]int, len(values))
+	for _, value := range values {
+		n, err := count(value)
+		if err != nil {
+			log.Printf("Error counting %s hotspots for project %s: %v", value, projectKey, err)
+			c.recordError(err)
+			return nil, err
+		}
+		counts[value] = n
+	}
+	return counts, nil
+}
+
+// exportCounts exports one series per counted value, in a stable order
+func (c *Collector) exportCounts(ch chan<- prometheus.Metric, desc *prometheus.Desc, project sonarqube.Component, counts map[string]int) {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.GaugeValue,
+			float64(counts[value]),
+			project.Key,
+			project.Name,
+			value,
+		)
+	}
+}
+
+// zeroCounts returns counts initialized to zero for the known values
+func zeroCounts(values []string) map[string]int {
+	counts := make(map[string]int, len(values))
+	for _, value := range values {
+		counts[value] = 0
+	}
+	return counts
+}
@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// hotspotServer is a mock SonarQube server of the hotspots of each project
+type hotspotServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	hotspots     map[string][]sonarqube.Hotspot
+	listRequests int
+}
+
+// newHotspotServer serves /api/hotspots/search from the hotspots of each project, filtered by
+// status and resolution and paged like SonarQube. Listing the hotspots of truncated projects
+// reports more hotspots than the search window holds.
+func newHotspotServer(t *testing.T, hotspots map[string][]sonarqube.Hotspot, truncated map[string]bool) *hotspotServer {
+	t.Helper()
+
+	s := &hotspotServer{hotspots: hotspots}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		query := r.URL.Query()
+		project := query.Get("project")
+
+		var matching []sonarqube.Hotspot
+		for _, hotspot := range s.hotspots[project] {
+			if status := query.Get("status"); status != "" && hotspot.Status != status {
+				continue
+			}
+			if resolution := query.Get("resolution"); resolution != "" && hotspot.Resolution != resolution {
+				continue
+			}
+			matching = append(matching, hotspot)
+		}
+
+		pageSize, _ := strconv.Atoi(query.Get("ps"))
+		pageIndex, _ := strconv.Atoi(query.Get("p"))
+		pageIndex = max(pageIndex, 1)
+
+		// Counts request a single hotspot, listings start from the first page
+		if pageSize > 1 && pageIndex == 1 {
+			s.listRequests++
+		}
+
+		response := sonarqube.HotspotsResponse{
+			Paging: sonarqube.Paging{PageIndex: pageIndex, PageSize: pageSize, Total: len(matching)},
+		}
+		if truncated[project] && query.Get("status") == "" {
+			response.Paging.Total = 10001
+			for range pageSize {
+				response.Hotspots = append(response.Hotspots, matching[0])
+			}
+		} else {
+			start := min((pageIndex-1)*pageSize, len(matching))
+			response.Hotspots = matching[start:min(start+pageSize, len(matching))]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(s.Server.Close)
+
+	return s
+}
+
+func (s *hotspotServer) setHotspots(project string, hotspots []sonarqube.Hotspot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hotspots[project] = hotspots
+}
+
+// takeListRequests returns the number of hotspot listings since the last call
+func (s *hotspotServer) takeListRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.listRequests
+	s.listRequests = 0
+	return requests
+}
+
+// hotspotValues returns the value of each hotspot series, by metric and status, resolution or
+// probability label value
+func hotspotValues(t *testing.T, ch <-chan prometheus.Metric) map[*prometheus.Desc]map[string]float64 {
+	t.Helper()
+
+	values := make(map[*prometheus.Desc]map[string]float64)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Failed to write metric: %v", err)
+		}
+
+		label := ""
+		for _, pair := range pb.GetLabel() {
+			if pair.GetName() != "project_key" && pair.GetName() != "project_name" {
+				label = pair.GetValue()
+			}
+		}
+
+		if values[m.Desc()] == nil {
+			values[m.Desc()] = make(map[string]float64)
+		}
+		values[m.Desc()][label] = pb.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestCollectHotspots(t *testing.T) {
+	server := newHotspotServer(t, map[string][]sonarqube.Hotspot{
+		"project1": {
+			{Key: "H1", Status: "TO_REVIEW", VulnerabilityProbability: "HIGH"},
+			{Key: "H2", Status: "REVIEWED", Resolution: "SAFE", VulnerabilityProbability: "LOW"},
+			{Key: "H3", Status: "REVIEWED", Resolution: "FIXED", VulnerabilityProbability: "LOW"},
+			{Key: "H4", Status: "TO_REVIEW", VulnerabilityProbability: "MEDIUM"},
+		},
+		"project3": {
+			{Key: "H5", Status: "REVIEWED", Resolution: "ACKNOWLEDGED", VulnerabilityProbability: "HIGH"},
+		},
+	}, map[string]bool{"project3": true})
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithHotspots(true))
+
+	tests := []struct {
+		project       string
+		byStatus      map[string]float64
+		byResolution  map[string]float64
+		byProbability map[string]float64
+		reviewed      float64
+	}{
+		{
+			project:       "project1",
+			byStatus:      map[string]float64{"TO_REVIEW": 2, "REVIEWED": 2},
+			byResolution:  map[string]float64{"FIXED": 1, "SAFE": 1, "ACKNOWLEDGED": 0},
+			byProbability: map[string]float64{"HIGH": 1, "MEDIUM": 1, "LOW": 2},
+			reviewed:      50,
+		},
+		{
+			// Every known value is exported, even with a zero count
+			project:       "project2",
+			byStatus:      map[string]float64{"TO_REVIEW": 0, "REVIEWED": 0},
+			byResolution:  map[string]float64{"FIXED": 0, "SAFE": 0, "ACKNOWLEDGED": 0},
+			byProbability: map[string]float64{"HIGH": 0, "MEDIUM": 0, "LOW": 0},
+			reviewed:      100,
+		},
+		{
+			// Beyond the search window, the partial probability counts are not exported
+			project:      "project3",
+			byStatus:     map[string]float64{"TO_REVIEW": 0, "REVIEWED": 1},
+			byResolution: map[string]float64{"FIXED": 0, "SAFE": 0, "ACKNOWLEDGED": 1},
+			reviewed:     100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			ch := make(chan prometheus.Metric, 20)
+			collector.collectHotspots(ch, sonarqube.Component{Key: tt.project, Name: tt.project})
+			close(ch)
+
+			values := hotspotValues(t, ch)
+
+			expected := map[*prometheus.Desc]map[string]float64{
+				collector.hotspotsByStatus:      tt.byStatus,
+				collector.hotspotsByResolution:  tt.byResolution,
+				collector.hotspotsByProbability: tt.byProbability,
+				collector.hotspotsReviewed:      {"": tt.reviewed},
+			}
+			for desc, counts := range expected {
+				if len(values[desc]) != len(counts) {
+					t.Errorf("Expected %d series of %s, got: %v", len(counts), desc, values[desc])
+				}
+				for label, count := range counts {
+					if value, ok := values[desc][label]; !ok || value != count {
+						t.Errorf("Expected %s %s to be %v, got: %v", desc, label, count, values[desc][label])
+					}
+				}
+			}
+		})
+	}
+
+	// The truncated hotspot list of project3 is reported
+	var pb dto.Metric
+	if err := collector.apiErrors.WithLabelValues("/api/hotspots/search", "truncated").Write(&pb); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	if pb.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 truncated hotspot search, got: %v", pb.GetCounter().GetValue())
+	}
+}
+
+func TestCollectHotspots_ProbabilityCache(t *testing.T) {
+	server := newHotspotServer(t, map[string][]sonarqube.Hotspot{
+		"project1": {
+			{Key: "H1", Status: "TO_REVIEW", VulnerabilityProbability: "HIGH"},
+			{Key: "H2", Status: "TO_REVIEW", VulnerabilityProbability: "LOW"},
+		},
+		"project2": {
+			{Key: "H3", Status: "TO_REVIEW", VulnerabilityProbability: "HIGH"},
+		},
+	}, map[string]bool{"project2": true})
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithHotspots(true))
+
+	project1 := sonarqube.Component{Key: "project1", Name: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"}
+	project2 := sonarqube.Component{Key: "project2", Name: "project2", AnalysisDate: "2024-01-15T10:30:00+0000"}
+
+	collect := func(project sonarqube.Component) map[*prometheus.Desc]map[string]float64 {
+		ch := make(chan prometheus.Metric, 20)
+		collector.collectHotspots(ch, project)
+		close(ch)
+		return hotspotValues(t, ch)
+	}
+
+	collect(project1)
+	if requests := server.takeListRequests(); requests != 1 {
+		t.Fatalf("Expected the hotspots of project1 to be listed, got %d listings", requests)
+	}
+
+	// A review changes the status counts, the probabilities are served from the cache
+	server.setHotspots("project1", []sonarqube.Hotspot{
+		{Key: "H1", Status: "REVIEWED", Resolution: "SAFE", VulnerabilityProbability: "HIGH"},
+		{Key: "H2", Status: "TO_REVIEW", VulnerabilityProbability: "LOW"},
+	})
+	values := collect(project1)
+	if requests := server.takeListRequests(); requests != 0 {
+		t.Errorf("Expected no listing before the next analysis, got: %d", requests)
+	}
+	if values[collector.hotspotsByStatus]["REVIEWED"] != 1 || values[collector.hotspotsReviewed][""] != 50 {
+		t.Errorf("Expected the review to be counted, got: %v", values)
+	}
+	if values[collector.hotspotsByProbability]["HIGH"] != 1 || values[collector.hotspotsByProbability]["LOW"] != 1 {
+		t.Errorf("Expected the cached probability counts, got: %v", values[collector.hotspotsByProbability])
+	}
+
+	// The next analysis lists the hotspots again
+	server.setHotspots("project1", []sonarqube.Hotspot{
+		{Key: "H2", Status: "TO_REVIEW", VulnerabilityProbability: "LOW"},
+	})
+	project1.AnalysisDate = "2024-01-16T09:00:00+0000"
+	values = collect(project1)
+	if requests := server.takeListRequests(); requests != 1 {
+		t.Errorf("Expected the hotspots to be listed after the analysis, got %d listings", requests)
+	}
+	if values[collector.hotspotsByProbability]["HIGH"] != 0 || values[collector.hotspotsByProbability]["LOW"] != 1 {
+		t.Errorf("Expected the probability counts of the new analysis, got: %v", values[collector.hotspotsByProbability])
+	}
+
+	// A truncated list is not listed again before the next analysis either
+	collect(project2)
+	server.takeListRequests()
+	if values := collect(project2); len(values[collector.hotspotsByProbability]) != 0 {
+		t.Errorf("Expected no probability counts of the truncated project, got: %v", values[collector.hotspotsByProbability])
+	}
+	if requests := server.takeListRequests(); requests != 0 {
+		t.Errorf("Expected no listing of the truncated project before the next analysis, got: %d", requests)
+	}
+
+	// Deleted projects are dropped from the cache
+	collector.pruneProbabilityCache([]sonarqube.Component{project2})
+	if _, exists := collector.probabilityCache["project1"]; exists || len(collector.probabilityCache) != 1 {
+		t.Errorf("Expected only project2 to remain cached, got: %v", collector.probabilityCache)
+	}
+}
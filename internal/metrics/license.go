@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dateLayout is the layout of the date-only values returned by the SonarQube Web API
+const dateLayout = "2006-01-02"
+
+// licenseRetryInterval is how long a license endpoint is not requested again after reporting the
+// license is not available, as the edition and the permissions of the token rarely change
+const licenseRetryInterval = time.Hour
+
+// WithLicense enables the license and lines of code consumption metrics of commercial editions.
+// They are skipped when the edition has no license or the token lacks administrator permissions.
+func WithLicense(enabled bool) Option {
+	return func(c *Collector) {
+		c.license = enabled
+	}
+}
+
+// collectLicense exports the license limits, its consumption and the consumption of each project
+func (c *Collector) collectLicense(ch chan<- prometheus.Metric) {
+	if c.now().Before(c.licenseRetryAt) {
+		return
+	}
+
+	license, err := c.client.GetLicense()
+	if err != nil {
+		c.licenseRetryAt = c.handleLicenseError("license", err)
+		return
+	}
+	c.licenseSkipped = false
+
+	ch <- prometheus.MustNewConstMetric(c.licenseInfo, prometheus.GaugeValue, 1, license.Edition, license.Type)
+	ch <- prometheus.MustNewConstMetric(c.licenseMaxLoc, prometheus.GaugeValue, float64(license.MaxLoc))
+	ch <- prometheus.MustNewConstMetric(c.licenseLoc, prometheus.GaugeValue, float64(license.Loc))
+
+	if license.MaxLoc > 0 {
+		ch <- prometheus.MustNewConstMetric(c.licenseUsage, prometheus.GaugeValue, float64(license.Loc)/float64(license.MaxLoc))
+	}
+
+	if license.ExpiresAt != "" {
+		if expiresAt, err := parseDate(license.ExpiresAt); err != nil {
+			log.Printf("Error parsing license expiration date: %v", err)
+		} else {
+			ch <- prometheus.MustNewConstMetric(c.licenseExpiration, prometheus.GaugeValue, float64(expiresAt.Unix()))
+		}
+	}
+
+	if c.now().Before(c.licenseUsageRetryAt) {
+		return
+	}
+
+	usages, err := c.client.GetLicenseUsage()
+	if err != nil {
+		c.licenseUsageRetryAt = c.handleLicenseError("license usage", err)
+		return
+	}
+
+	for _, usage := range usages {
+		ch <- prometheus.MustNewConstMetric(
+			c.licenseProjectLoc,
+			prometheus.GaugeValue,
+			float64(usage.LinesOfCode),
+			usage.ProjectKey,
+			usage.ProjectName,
+		)
+	}
+}
+
+// handleLicenseError logs license errors and returns when to request the endpoint again. Editions
+// without a license and missing permissions are expected: they are logged once, aren't counted as
+// API errors, and the endpoint is only requested again after licenseRetryInterval.
+func (c *Collector) handleLicenseError(what string, err error) time.Time {
+	if !errors.Is(err, sonarqube.ErrForbidden) && !errors.Is(err, sonarqube.ErrNotFound) {
+		log.Printf("Error fetching %s: %v", what, err)
+		c.recordError(err)
+		return time.Time{}
+	}
+
+	if !c.licenseSkipped {
+		log.Printf("Skipping license metrics, the %s is not available (commercial edition and administrator permissions required): %v", what, err)
+		c.licenseSkipped = true
+	}
+	return c.now().Add(licenseRetryInterval)
+}
+
+// parseDate parses a date returned by the SonarQube Web API, either a date or a date-time
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	return sonarqube.ParseDateTime(value)
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectLicense(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/editions/show_license":
+			json.NewEncoder(w).Encode(sonarqube.License{
+				Edition:   "Enterprise",
+				Type:      "PRODUCTION",
+				MaxLoc:    1000000,
+				Loc:       950000,
+				ExpiresAt: "2030-01-31",
+			})
+		case "/api/projects/license_usage":
+			json.NewEncoder(w).Encode(sonarqube.LicenseUsageResponse{
+				Projects: []sonarqube.ProjectLicenseUsage{
+					{ProjectKey: "project1", ProjectName: "Project 1", LinesOfCode: 900000},
+					{ProjectKey: "project2", ProjectName: "Project 2", LinesOfCode: 50000},
+				},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithLicense(true))
+
+	ch := make(chan prometheus.Metric, 20)
+	collector.collectLicense(ch)
+	close(ch)
+
+	// info, max loc, loc, usage ratio, expiration and 2 projects
+	count := 0
+	for range ch {
+		count++
+	}
+
+	if count != 7 {
+		t.Errorf("Expected 7 metrics, got: %d", count)
+	}
+}
+
+func TestCollectLicense_Skipped(t *testing.T) {
+	requests := make(map[string]int)
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+
+	// Once permissions are granted, the license is readable, but not its usage on this version
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Path != "/api/editions/show_license" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sonarqube.License{Edition: "Enterprise", Type: "PRODUCTION"})
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	client := sonarqube.NewClient(forbidden.URL, "test-token")
+	collector := NewCollector(client, WithLicense(true))
+	collector.now = func() time.Time { return now }
+
+	collect := func() int {
+		ch := make(chan prometheus.Metric, 20)
+		collector.collectLicense(ch)
+		close(ch)
+		return len(ch)
+	}
+
+	if count := collect(); count != 0 {
+		t.Errorf("Expected no metrics without permissions, got: %d", count)
+	}
+	if !collector.licenseSkipped {
+		t.Error("Expected license metrics to be marked as skipped")
+	}
+
+	// The license is not requested again until the retry interval elapsed
+	collect()
+	if requests["/api/editions/show_license"] != 1 {
+		t.Errorf("Expected 1 license request within the retry interval, got: %d", requests["/api/editions/show_license"])
+	}
+
+	// The expected skip is not an API error
+	ch := make(chan prometheus.Metric, 10)
+	collector.apiErrors.Collect(ch)
+	close(ch)
+	if len(ch) != 0 {
+		t.Errorf("Expected no API errors for a skipped license, got: %d", len(ch))
+	}
+
+	// Once the interval elapsed, the license is requested again, each endpoint backing off on its own
+	now = now.Add(licenseRetryInterval)
+	requests = make(map[string]int)
+	collector.client = sonarqube.NewClient(server.URL, "test-token")
+
+	if count := collect(); count != 3 {
+		t.Errorf("Expected info, max loc and loc of the license, got %d metrics", count)
+	}
+	if count := collect(); count != 3 {
+		t.Errorf("Expected the license to still be collected, got %d metrics", count)
+	}
+	if requests["/api/editions/show_license"] != 2 || requests["/api/projects/license_usage"] != 1 {
+		t.Errorf("Expected 2 license requests and 1 license usage request, got: %v", requests)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"2030-01-31", "2030-01-31T00:00:00+0000"} {
+		parsed, err := parseDate(value)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", value, err)
+		}
+		if !parsed.Equal(expected) {
+			t.Errorf("Expected %v for %s, got: %v", expected, value, parsed)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// cachedMeasures holds the measures of a project fetched after its analysis of the given date
+type cachedMeasures struct {
+	analysisDate string
+	measures     []sonarqube.Measure
+}
+
+// WithIncrementalRefresh caches the measures of each project and fetches them again only when the
+// project is re-analyzed, i.e. when its analysis date moves. All measures are fetched again every
+// resync interval, to pick up the changes that don't come with an analysis (e.g. issues marked as
+// false positives). A zero interval disables the full resync.
+func WithIncrementalRefresh(enabled bool, resyncInterval time.Duration) Option {
+	return func(c *Collector) {
+		c.incrementalRefresh = enabled
+		c.resyncInterval = resyncInterval
+		c.measureCache = make(map[string]cachedMeasures)
+	}
+}
+
+// prepareMeasureCache empties the measure cache when a full resync is due or when the fetched
+// metrics changed, and drops the measures of deleted projects
+func (c *Collector) prepareMeasureCache(projects []sonarqube.Component, metricKeys []string) {
+	keys := strings.Join(metricKeys, ",")
+	now := c.now()
+
+	catalogChanged := keys != c.measureCacheKeys
+	resyncDue := c.resyncInterval > 0 && now.Sub(c.lastResync) >= c.resyncInterval
+
+	if catalogChanged || resyncDue {
+		if catalogChanged && c.measureCacheKeys != "" {
+			log.Printf("Fetched metrics changed, fetching the measures of all projects")
+		}
+
+		c.measureCache = make(map[string]cachedMeasures)
+		c.measureCacheKeys = keys
+		c.lastResync = now
+		return
+	}
+
+	current := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		current[project.Key] = true
+	}
+	for key := range c.measureCache {
+		if !current[key] {
+			delete(c.measureCache, key)
+		}
+	}
+}
+
+// projectMeasures returns the measures of a project, from the cache if the project has not been
+// analyzed since they were fetched
+func (c *Collector) projectMeasures(project sonarqube.Component, metricKeys []string) ([]sonarqube.Measure, error) {
+	if c.incrementalRefresh {
+		if cached, exists := c.measureCache[project.Key]; exists && sameAnalysisDate(cached.analysisDate, project.AnalysisDate) {
+			return cached.measures, nil
+		}
+	}
+
+	measures, err := c.client.GetProjectMeasures(project.Key, metricKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.incrementalRefresh {
+		c.measureCache[project.Key] = cachedMeasures{analysisDate: project.AnalysisDate, measures: measures}
+	}
+
+	return measures, nil
+}
+
+// sameAnalysisDate reports whether two analysis dates are the same instant, whatever their time
+// zone offset, as dates notified by webhooks may be formatted differently from the project ones
+func sameAnalysisDate(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	dateA, errA := sonarqube.ParseDateTime(a)
+	dateB, errB := sonarqube.ParseDateTime(b)
+	return errA == nil && errB == nil && dateA.Equal(dateB)
+}
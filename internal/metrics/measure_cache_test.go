@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fleetServer is a mock SonarQube server whose projects can be re-analyzed, and which counts the
+// project searches and the measure requests of each project
+type fleetServer struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	projects        []sonarqube.Component
+	projectRequests int
+	measureRequests map[string]int
+}
+
+func newFleetServer(projects ...sonarqube.Component) *fleetServer {
+	s := &fleetServer{projects: projects, measureRequests: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{{Key: "bugs", Type: "INT", Name: "Bugs"}},
+				Total:   1,
+			})
+		case "/api/components/search_projects":
+			s.projectRequests++
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging:     sonarqube.Paging{Total: len(s.projects)},
+				Components: s.projects,
+			})
+		case "/api/measures/component":
+			component := r.URL.Query().Get("component")
+			s.measureRequests[component]++
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key:      component,
+					Measures: []sonarqube.Measure{{Metric: "bugs", Value: "5"}},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *fleetServer) setProjects(projects ...sonarqube.Component) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.projects = projects
+}
+
+// takeProjectRequests returns the number of project searches since the last call
+func (s *fleetServer) takeProjectRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.projectRequests
+	s.projectRequests = 0
+	return requests
+}
+
+// takeMeasureRequests returns the measure requests of each project since the last call
+func (s *fleetServer) takeMeasureRequests() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.measureRequests
+	s.measureRequests = make(map[string]int)
+	return requests
+}
+
+// collectCount runs Collect and returns the number of metrics collected
+func collectCount(collector *Collector) int {
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+
+	count := 0
+	for range ch {
+		count++
+	}
+	return count
+}
+
+func TestIncrementalRefresh(t *testing.T) {
+	project1 := sonarqube.Component{Key: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"}
+	project2 := sonarqube.Component{Key: "project2", AnalysisDate: "2024-01-10T08:00:00+0000"}
+
+	server := newFleetServer(project1, project2)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithIncrementalRefresh(true, time.Hour))
+	collector.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		update   func()
+		expected map[string]int
+	}{
+		{
+			name:     "first scrape fetches all projects",
+			update:   func() {},
+			expected: map[string]int{"project1": 1, "project2": 1},
+		},
+		{
+			name:     "idle projects are served from the cache",
+			update:   func() { now = now.Add(10 * time.Minute) },
+			expected: map[string]int{},
+		},
+		{
+			name: "re-analyzed project is fetched again",
+			update: func() {
+				project1.AnalysisDate = "2024-01-16T00:05:00+0000"
+				server.setProjects(project1, project2)
+			},
+			expected: map[string]int{"project1": 1},
+		},
+		{
+			name:     "full resync fetches all projects",
+			update:   func() { now = now.Add(time.Hour) },
+			expected: map[string]int{"project1": 1, "project2": 1},
+		},
+	}
+
+	for _, step := range steps {
+		step.update()
+		count := collectCount(collector)
+
+		// metric catalog size, 2 project_info, 2 last analysis timestamps, never analyzed and 2 bugs
+		if count != 8 {
+			t.Errorf("%s: expected 8 metrics, got: %d", step.name, count)
+		}
+
+		requests := server.takeMeasureRequests()
+		if len(requests) != len(step.expected) {
+			t.Errorf("%s: expected measure requests %v, got: %v", step.name, step.expected, requests)
+			continue
+		}
+		for key, expected := range step.expected {
+			if requests[key] != expected {
+				t.Errorf("%s: expected measure requests %v, got: %v", step.name, step.expected, requests)
+			}
+		}
+	}
+
+	// Deleted projects are dropped from the cache
+	server.setProjects(project1)
+	collectCount(collector)
+	if _, exists := collector.measureCache["project2"]; exists {
+		t.Error("Expected measures of the deleted project to be dropped from the cache")
+	}
+}
+
+func TestIncrementalRefresh_Disabled(t *testing.T) {
+	server := newFleetServer(sonarqube.Component{Key: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"})
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client)
+
+	collectCount(collector)
+	collectCount(collector)
+
+	if requests := server.takeMeasureRequests(); requests["project1"] != 2 {
+		t.Errorf("Expected measures to be fetched on every scrape, got: %v", requests)
+	}
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/notify"
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// maxConcurrentNotifications bounds the notifications sent at once, e.g. when a change of the
+// quality gate makes many projects fail at the same time
+const maxConcurrentNotifications = 4
+
+// gateTransition is a change of the quality gate status of a project between two collections
+type gateTransition struct {
+	project  sonarqube.Component
+	previous string
+	status   string
+}
+
+// WithQualityGateNotifications sends a notification when the quality gate status of a project
+// changes between two collections, e.g. from OK to ERROR
+func WithQualityGateNotifications(notifier *notify.Notifier) Option {
+	return func(c *Collector) {
+		c.notifier = notifier
+		c.notifySlots = make(chan struct{}, maxConcurrentNotifications)
+	}
+}
+
+// qualityGateStatusesOf returns the quality gate status of each project of a snapshot
+func qualityGateStatusesOf(snap *snapshot) map[string]string {
+	statuses := make(map[string]string, len(snap.Projects))
+	for key, measures := range snap.Measures {
+		for _, measure := range measures {
+			if measure.Metric == qualityGateMetricKey && measure.Value != "" {
+				statuses[key] = measure.Value
+			}
+		}
+	}
+	return statuses
+}
+
+// notifyQualityGateTransitions compares the quality gate statuses of a collection with the previous
+// one, and notifies the projects whose status changed. Projects whose measures could not be fetched
+// keep their previous status, and new projects are only compared from the next collection on.
+func (c *Collector) notifyQualityGateTransitions(snap *snapshot) {
+	statuses := qualityGateStatusesOf(snap)
+	var transitions []gateTransition
+
+	for _, project := range snap.Projects {
+		previous, known := c.gateStatuses[project.Key]
+		status, exists := statuses[project.Key]
+
+		switch {
+		case !known:
+		case !exists:
+			if _, fetched := snap.Measures[project.Key]; !fetched {
+				statuses[project.Key] = previous
+			}
+		case status != previous:
+			transitions = append(transitions, gateTransition{project: project, previous: previous, status: status})
+		}
+	}
+
+	c.gateStatuses = statuses
+
+	// Notifications are sent in the background, not to delay the collection
+	if len(transitions) > 0 {
+		c.notifyDone.Add(1)
+		go c.sendNotifications(transitions)
+	}
+}
+
+// sendNotifications sends the notifications of quality gate transitions, at most
+// maxConcurrentNotifications at once across collections
+func (c *Collector) sendNotifications(transitions []gateTransition) {
+	defer c.notifyDone.Done()
+
+	for _, transition := range transitions {
+		c.notifySlots <- struct{}{}
+		c.notifyDone.Add(1)
+		go func() {
+			defer func() { <-c.notifySlots }()
+			c.notifyQualityGateTransition(transition.project, transition.previous, transition.status)
+		}()
+	}
+}
+
+// notifyQualityGateTransition sends the notification of a quality gate transition, with the failing
+// conditions and the main branch of the project
+func (c *Collector) notifyQualityGateTransition(project sonarqube.Component, previous, status string) {
+	defer c.notifyDone.Done()
+
+	notification := notify.Notification{
+		ProjectKey:     project.Key,
+		ProjectName:    project.Name,
+		PreviousStatus: previous,
+		Status:         status,
+		Conditions:     []notify.Condition{},
+		DashboardURL:   c.client.DashboardURL(project.Key),
+	}
+
+	// The notification is sent even without these details
+	branch, err := c.client.GetMainBranch(project.Key)
+	if err != nil {
+		log.Printf("Error fetching main branch for project %s: %v", project.Key, err)
+		c.recordError(err)
+	}
+	notification.Branch = branch
+
+	gate, err := c.client.GetQualityGateStatus(project.Key)
+	if err != nil {
+		log.Printf("Error fetching quality gate status for project %s: %v", project.Key, err)
+		c.recordError(err)
+	} else {
+		for _, condition := range gate.Conditions {
+			if condition.Status == "OK" {
+				continue
+			}
+			notification.Conditions = append(notification.Conditions, notify.Condition{
+				Metric:         condition.MetricKey,
+				Comparator:     condition.Comparator,
+				ErrorThreshold: condition.ErrorThreshold,
+				ActualValue:    condition.ActualValue,
+				Status:         condition.Status,
+			})
+		}
+	}
+
+	if err := c.notifier.Notify(notification); err != nil {
+		log.Printf("Error notifying quality gate transition of project %s: %v", project.Key, err)
+		return
+	}
+	log.Printf("Notified quality gate transition of project %s from %s to %s", project.Key, previous, status)
+}
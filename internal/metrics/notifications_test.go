@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/notify"
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// gateTransitionServer is a mock SonarQube server whose project quality gate status can be changed
+type gateTransitionServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	status      string
+	measuresErr bool
+}
+
+func newGateTransitionServer(status string) *gateTransitionServer {
+	s := &gateTransitionServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{{Key: "alert_status", Type: "LEVEL", Name: "Quality Gate Status"}},
+				Total:   1,
+			})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging:     sonarqube.Paging{Total: 1},
+				Components: []sonarqube.Component{{Key: "project1", Name: "Project 1", AnalysisDate: "2024-01-15T10:30:00+0000"}},
+			})
+		case "/api/measures/component":
+			if s.measuresErr {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key:      "project1",
+					Measures: []sonarqube.Measure{{Metric: "alert_status", Value: s.status}},
+				},
+			})
+		case "/api/qualitygates/project_status":
+			json.NewEncoder(w).Encode(sonarqube.ProjectStatusResponse{
+				ProjectStatus: sonarqube.ProjectStatus{
+					Status: s.status,
+					Conditions: []sonarqube.QualityGateCondition{
+						{Status: s.status, MetricKey: "new_coverage", Comparator: "LT", ErrorThreshold: "80", ActualValue: "42.5"},
+					},
+				},
+			})
+		case "/api/project_branches/list":
+			json.NewEncoder(w).Encode(sonarqube.ProjectBranchesResponse{
+				Branches: []sonarqube.ProjectBranch{{Name: "main", Type: "BRANCH", IsMain: true}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *gateTransitionServer) set(status string, measuresErr bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+	s.measuresErr = measuresErr
+}
+
+func TestQualityGateNotifications(t *testing.T) {
+	server := newGateTransitionServer("OK")
+	defer server.Close()
+
+	var mu sync.Mutex
+	var texts []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+
+		mu.Lock()
+		defer mu.Unlock()
+		texts = append(texts, message.Text)
+	}))
+	defer receiver.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithQualityGateNotifications(notify.New([]string{receiver.URL})))
+
+	steps := []struct {
+		name        string
+		status      string
+		measuresErr bool
+		expected    []string
+	}{
+		{name: "first collection has nothing to compare", status: "OK"},
+		{name: "regression is notified", status: "ERROR", expected: []string{"from OK to *ERROR*", "(main)", "new_coverage is 42.5 (LT 80)", "/dashboard?id=project1"}},
+		{name: "unchanged status is not notified", status: "ERROR"},
+		{name: "failed fetch keeps the previous status", status: "OK", measuresErr: true},
+		{name: "recovery is notified", status: "OK", expected: []string{"from ERROR to *OK*"}},
+	}
+
+	for _, step := range steps {
+		server.set(step.status, step.measuresErr)
+		collectCount(collector)
+		collector.notifyDone.Wait()
+
+		mu.Lock()
+		sent := texts
+		texts = nil
+		mu.Unlock()
+
+		if len(step.expected) == 0 {
+			if len(sent) != 0 {
+				t.Errorf("%s: expected no notification, got: %v", step.name, sent)
+			}
+			continue
+		}
+
+		if len(sent) != 1 {
+			t.Errorf("%s: expected 1 notification, got: %v", step.name, sent)
+			continue
+		}
+		for _, expected := range step.expected {
+			if !strings.Contains(sent[0], expected) {
+				t.Errorf("%s: expected notification to contain %q, got: %s", step.name, expected, sent[0])
+			}
+		}
+	}
+}
+
+func TestQualityGateNotifications_Concurrency(t *testing.T) {
+	server := newGateTransitionServer("ERROR")
+	defer server.Close()
+
+	var mu sync.Mutex
+	inFlight, maxInFlight, sent := 0, 0, 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		sent++
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithQualityGateNotifications(notify.New([]string{receiver.URL})))
+
+	// A change of the quality gate makes many projects fail at once
+	snap := newSnapshot(nil, nil, time.Now())
+	collector.gateStatuses = make(map[string]string)
+	for i := range 10 {
+		project := sonarqube.Component{Key: fmt.Sprintf("project%d", i)}
+		snap.Projects = append(snap.Projects, project)
+		snap.Measures[project.Key] = []sonarqube.Measure{{Metric: qualityGateMetricKey, Value: "ERROR"}}
+		collector.gateStatuses[project.Key] = "OK"
+	}
+
+	collector.notifyQualityGateTransitions(snap)
+	collector.notifyDone.Wait()
+
+	if sent != 10 {
+		t.Errorf("Expected 10 notifications, got: %d", sent)
+	}
+	if maxInFlight > maxConcurrentNotifications {
+		t.Errorf("Expected at most %d notifications at once, got: %d", maxConcurrentNotifications, maxInFlight)
+	}
+}
+
+func TestCollector_Close(t *testing.T) {
+	server := newGateTransitionServer("ERROR")
+	defer server.Close()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	sent := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		sent++
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithQualityGateNotifications(notify.New([]string{receiver.URL})))
+
+	project := sonarqube.Component{Key: "project1"}
+	snap := newSnapshot(nil, []sonarqube.Component{project}, time.Now())
+	snap.Measures[project.Key] = []sonarqube.Measure{{Metric: qualityGateMetricKey, Value: "ERROR"}}
+	collector.gateStatuses = map[string]string{project.Key: "OK"}
+	collector.notifyQualityGateTransitions(snap)
+
+	// Close gives up on a notification still being sent once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := collector.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Close to time out, got: %v", err)
+	}
+
+	// Otherwise it waits for the notification to be sent
+	close(release)
+	if err := collector.Close(context.Background()); err != nil {
+		t.Fatalf("Expected Close to succeed, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sent != 1 {
+		t.Errorf("Expected 1 notification, got: %d", sent)
+	}
+}
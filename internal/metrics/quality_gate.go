@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// qualityGateMetricKey is the key of the measure holding the quality gate status of a project
+const qualityGateMetricKey = "alert_status"
+
+// qualityGateStatuses are the quality gate statuses exported as a state set. WARN is only
+// reported by SonarQube versions older than 7.6.
+var qualityGateStatuses = []string{"OK", "WARN", "ERROR"}
+
+// withQualityGateKey adds the quality gate status to the fetched metric keys, if the catalog has it
+func withQualityGateKey(metricKeys []string, metrics []sonarqube.Metric) []string {
+	for _, metric := range metrics {
+		if metric.Key == qualityGateMetricKey {
+			return append(metricKeys, qualityGateMetricKey)
+		}
+	}
+	return metricKeys
+}
+
+// exportQualityGate exports the quality gate status of a project as a state set, stamped with the
+// timestamp unless it is zero
+func (c *Collector) exportQualityGate(ch chan<- prometheus.Metric, project sonarqube.Component, status string, timestamp time.Time) {
+	for _, candidate := range qualityGateStatuses {
+		state := 0.0
+		if candidate == status {
+			state = 1
+		}
+
+		ch <- withTimestamp(prometheus.MustNewConstMetric(
+			c.qualityGateStatus,
+			prometheus.GaugeValue,
+			state,
+			project.Key,
+			project.Name,
+			candidate,
+		), timestamp)
+	}
+}
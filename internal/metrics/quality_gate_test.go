@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestWithQualityGateKey(t *testing.T) {
+	keys := withQualityGateKey([]string{"bugs"}, []sonarqube.Metric{{Key: "bugs"}, {Key: "alert_status"}})
+	if len(keys) != 2 || keys[1] != "alert_status" {
+		t.Errorf("Expected alert_status to be fetched, got: %v", keys)
+	}
+
+	keys = withQualityGateKey([]string{"bugs"}, []sonarqube.Metric{{Key: "bugs"}})
+	if len(keys) != 1 {
+		t.Errorf("Expected alert_status not to be fetched when missing from the catalog, got: %v", keys)
+	}
+}
+
+func TestExportQualityGate(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client)
+	project := sonarqube.Component{Key: "project1", Name: "Project 1"}
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.exportQualityGate(ch, project, "ERROR", time.Time{})
+	close(ch)
+
+	states := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		metric.Write(&m)
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "status" {
+				states[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+
+	expected := map[string]float64{"OK": 0, "WARN": 0, "ERROR": 1}
+	for status, value := range expected {
+		if states[status] != value {
+			t.Errorf("Expected status %s to be %v, got: %v", status, value, states[status])
+		}
+	}
+}
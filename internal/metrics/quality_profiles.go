@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithQualityProfiles enables the quality profile inventory and the quality profile used by
+// each project. Projects whose profile is neither one of the standard profiles nor inherits from
+// one of them are flagged by the drift gauge, which is only exported when standards are given.
+func WithQualityProfiles(enabled bool, standards []string) Option {
+	return func(c *Collector) {
+		c.qualityProfiles = enabled
+		c.standardProfiles = make(map[string]bool, len(standards))
+		for _, name := range standards {
+			c.standardProfiles[name] = true
+		}
+	}
+}
+
+// collectQualityProfiles exports the quality profile inventory and returns the profiles by key
+func (c *Collector) collectQualityProfiles(ch chan<- prometheus.Metric) map[string]sonarqube.QualityProfile {
+	profiles, err := c.client.GetQualityProfiles()
+	if err != nil {
+		log.Printf("Error fetching quality profiles: %v", err)
+		c.recordError(err)
+		return nil
+	}
+
+	byKey := make(map[string]sonarqube.QualityProfile, len(profiles))
+	for _, profile := range profiles {
+		byKey[profile.Key] = profile
+		labels := []string{profile.Key, profile.Name, profile.Language}
+
+		ch <- prometheus.MustNewConstMetric(c.profileActiveRules, prometheus.GaugeValue, float64(profile.ActiveRuleCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.profileDeprecatedRules, prometheus.GaugeValue, float64(profile.ActiveDeprecatedRuleCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.profileDefault, prometheus.GaugeValue, boolToFloat(profile.IsDefault), labels...)
+		ch <- prometheus.MustNewConstMetric(c.profileBuiltIn, prometheus.GaugeValue, boolToFloat(profile.IsBuiltIn), labels...)
+	}
+
+	return byKey
+}
+
+// collectProjectQualityProfiles exports the quality profiles used by a project and, when
+// standard profiles are configured, whether they drifted from them
+func (c *Collector) collectProjectQualityProfiles(ch chan<- prometheus.Metric, project sonarqube.Component, profiles map[string]sonarqube.QualityProfile) {
+	projectProfiles, err := c.client.GetProjectQualityProfiles(project.Key)
+	if err != nil {
+		log.Printf("Error fetching quality profiles for project %s: %v", project.Key, err)
+		c.recordError(err)
+		return
+	}
+
+	for _, profile := range projectProfiles {
+		ch <- prometheus.MustNewConstMetric(
+			c.projectProfile,
+			prometheus.GaugeValue,
+			1,
+			project.Key,
+			project.Name,
+			profile.Language,
+			profile.Key,
+			profile.Name,
+		)
+
+		// Drift can't be computed without the inventory to walk the inheritance chain
+		if len(c.standardProfiles) == 0 || profiles == nil {
+			continue
+		}
+
+		drift := 1.0
+		if c.inheritsFromStandard(profile, profiles) {
+			drift = 0
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.projectProfileDrift,
+			prometheus.GaugeValue,
+			drift,
+			project.Key,
+			project.Name,
+			profile.Language,
+			profile.Name,
+		)
+	}
+}
+
+// inheritsFromStandard reports whether a profile is a standard profile or one of its ancestors is
+func (c *Collector) inheritsFromStandard(profile sonarqube.QualityProfile, profiles map[string]sonarqube.QualityProfile) bool {
+	visited := make(map[string]bool)
+
+	for {
+		if c.standardProfiles[profile.Name] {
+			return true
+		}
+
+		if profile.ParentKey == "" || visited[profile.ParentKey] {
+			return false
+		}
+		visited[profile.ParentKey] = true
+
+		parent, exists := profiles[profile.ParentKey]
+		if !exists {
+			// Unknown parent, only its name is known
+			return c.standardProfiles[profile.ParentName]
+		}
+		profile = parent
+	}
+}
+
+// boolToFloat converts a boolean to a gauge value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
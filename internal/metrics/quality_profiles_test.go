@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInheritsFromStandard(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithQualityProfiles(true, []string{"Company Way"}))
+
+	profiles := map[string]sonarqube.QualityProfile{
+		"sonar-way": {Key: "sonar-way", Name: "Sonar way"},
+		"company":   {Key: "company", Name: "Company Way", ParentKey: "sonar-way"},
+		"team":      {Key: "team", Name: "Team Way", ParentKey: "company"},
+		"rogue":     {Key: "rogue", Name: "Rogue", ParentKey: "sonar-way"},
+		"loop-a":    {Key: "loop-a", Name: "Loop A", ParentKey: "loop-b"},
+		"loop-b":    {Key: "loop-b", Name: "Loop B", ParentKey: "loop-a"},
+	}
+
+	tests := []struct {
+		profile  sonarqube.QualityProfile
+		expected bool
+	}{
+		{profile: profiles["company"], expected: true},
+		{profile: profiles["team"], expected: true},
+		{profile: profiles["rogue"], expected: false},
+		{profile: profiles["sonar-way"], expected: false},
+		{profile: profiles["loop-a"], expected: false},
+		{profile: sonarqube.QualityProfile{Name: "Unknown", ParentKey: "missing", ParentName: "Company Way"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile.Name, func(t *testing.T) {
+			if result := collector.inheritsFromStandard(tt.profile, profiles); result != tt.expected {
+				t.Errorf("Expected %v, got: %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCollectQualityProfiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profiles := []sonarqube.QualityProfile{
+			{Key: "java-sonar-way", Name: "Sonar way", Language: "java", IsDefault: true, IsBuiltIn: true},
+			{Key: "java-company", Name: "Company Way", Language: "java", ParentKey: "java-sonar-way"},
+			{Key: "js-sonar-way", Name: "Sonar way", Language: "js", IsDefault: true, IsBuiltIn: true},
+		}
+		if r.URL.Query().Get("project") == "project1" {
+			profiles = []sonarqube.QualityProfile{profiles[1], profiles[2]}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sonarqube.QualityProfilesResponse{Profiles: profiles})
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithQualityProfiles(true, []string{"Company Way"}))
+
+	ch := make(chan prometheus.Metric, 50)
+	profiles := collector.collectQualityProfiles(ch)
+	collector.collectProjectQualityProfiles(ch, sonarqube.Component{Key: "project1", Name: "Project 1"}, profiles)
+	close(ch)
+
+	if len(profiles) != 3 {
+		t.Errorf("Expected 3 profiles, got: %d", len(profiles))
+	}
+
+	inventory := 0
+	projectProfiles := 0
+	drifts := map[string]float64{}
+	for m := range ch {
+		switch m.Desc() {
+		case collector.projectProfile:
+			projectProfiles++
+		case collector.projectProfileDrift:
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				t.Fatalf("Failed to write metric: %v", err)
+			}
+			for _, label := range pb.GetLabel() {
+				if label.GetName() == "language" {
+					drifts[label.GetValue()] = pb.GetGauge().GetValue()
+				}
+			}
+		default:
+			inventory++
+		}
+	}
+
+	// 4 metrics per profile in the inventory
+	if inventory != 12 {
+		t.Errorf("Expected 12 inventory metrics, got: %d", inventory)
+	}
+	if projectProfiles != 2 {
+		t.Errorf("Expected 2 project profile metrics, got: %d", projectProfiles)
+	}
+	if drifts["java"] != 0 || drifts["js"] != 1 {
+		t.Errorf("Expected java to follow the standard and js to drift, got: %v", drifts)
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithSecurityReports enables the security report metrics for the given standards,
+// such as owaspTop10-2021 or cwe
+func WithSecurityReports(standards []string) Option {
+	return func(c *Collector) {
+		c.securityStandards = standards
+	}
+}
+
+// collectSecurityReports exports the vulnerabilities and hotspots per category of a project,
+// for each configured security standard
+func (c *Collector) collectSecurityReports(ch chan<- prometheus.Metric, project sonarqube.Component) {
+	for _, standard := range c.securityStandards {
+		report, err := c.client.GetSecurityReport(project.Key, standard)
+		if err != nil {
+			log.Printf("Error fetching %s security report for project %s: %v", standard, project.Key, err)
+			c.recordError(err)
+			continue
+		}
+
+		for _, category := range report.Categories {
+			ch <- prometheus.MustNewConstMetric(
+				c.securityVulnerabilities,
+				prometheus.GaugeValue,
+				float64(category.Vulnerabilities),
+				project.Key,
+				project.Name,
+				standard,
+				category.Category,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				c.securityHotspots,
+				prometheus.GaugeValue,
+				float64(category.ToReviewSecurityHotspots),
+				project.Key,
+				project.Name,
+				standard,
+				category.Category,
+				"TO_REVIEW",
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				c.securityHotspots,
+				prometheus.GaugeValue,
+				float64(category.ReviewedSecurityHotspots),
+				project.Key,
+				project.Name,
+				standard,
+				category.Category,
+				"REVIEWED",
+			)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectSecurityReports(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("standard") == sonarqube.SecurityStandardCWE {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sonarqube.SecurityReport{
+			Categories: []sonarqube.SecurityReportCategory{
+				{Category: "a1", Vulnerabilities: 2, ToReviewSecurityHotspots: 1, ReviewedSecurityHotspots: 3},
+				{Category: "a2"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithSecurityReports([]string{
+		sonarqube.SecurityStandardOWASPTop102021,
+		sonarqube.SecurityStandardCWE,
+	}))
+
+	ch := make(chan prometheus.Metric, 20)
+	collector.collectSecurityReports(ch, sonarqube.Component{Key: "project1", Name: "Project 1"})
+	close(ch)
+
+	vulnerabilities := 0
+	hotspots := 0
+	for m := range ch {
+		switch m.Desc() {
+		case collector.securityVulnerabilities:
+			vulnerabilities++
+		case collector.securityHotspots:
+			hotspots++
+		}
+	}
+
+	// The cwe report fails, the owaspTop10-2021 one has 2 categories
+	if vulnerabilities != 2 {
+		t.Errorf("Expected 2 vulnerabilities metrics, got: %d", vulnerabilities)
+	}
+	if hotspots != 4 {
+		t.Errorf("Expected 4 hotspots metrics, got: %d", hotspots)
+	}
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// snapshotVersion is the version of the snapshot file format. Snapshots of another version are
+// ignored, the first refresh then starting from scratch.
+const snapshotVersion = 1
+
+// snapshot is the data of a collection: the metric catalog, the projects and their measures,
+// which include the quality gate status
+type snapshot struct {
+	Version  int                            `json:"version"`
+	TakenAt  time.Time                      `json:"takenAt"`
+	Metrics  []sonarqube.Metric             `json:"metrics"`
+	Projects []sonarqube.Component          `json:"projects"`
+	Measures map[string][]sonarqube.Measure `json:"measures"`
+}
+
+// newSnapshot creates a snapshot of the metric catalog and projects, to be filled with measures
+func newSnapshot(metrics []sonarqube.Metric, projects []sonarqube.Component, takenAt time.Time) *snapshot {
+	return &snapshot{
+		Version:  snapshotVersion,
+		TakenAt:  takenAt,
+		Metrics:  metrics,
+		Projects: projects,
+		Measures: make(map[string][]sonarqube.Measure, len(projects)),
+	}
+}
+
+// WithSnapshot persists the data of each complete collection to a file. Once loaded with
+// LoadSnapshot, it is served after a restart until the first refresh completes.
+func WithSnapshot(path string) Option {
+	return func(c *Collector) {
+		c.snapshotPath = path
+	}
+}
+
+// LoadSnapshot loads the snapshot file, if any. Scrapes then serve the snapshot, flagged by the
+// sonarqube_exporter_snapshot_stale gauge, while the first refresh runs in the background.
+// Metrics of the optional features are not part of the snapshot, and only come back with the refresh.
+func (c *Collector) LoadSnapshot() error {
+	if c.snapshotPath == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(c.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(content, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot %s: %w", c.snapshotPath, err)
+	}
+	if snap.Version != snapshotVersion {
+		log.Printf("Ignoring snapshot %s of version %d, expected version %d", c.snapshotPath, snap.Version, snapshotVersion)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.updateMetricNames(snap.Metrics)
+	metrics := c.exportSnapshot(&snap)
+
+	// The snapshot also warms up the caches, so that the first refresh only fetches what changed
+	c.catalogMu.Lock()
+	c.catalog = snap.Metrics
+	c.catalogFetched = snap.TakenAt
+	c.catalogMu.Unlock()
+
+	if c.incrementalRefresh {
+		for _, project := range snap.Projects {
+			if measures, exists := snap.Measures[project.Key]; exists {
+				c.measureCache[project.Key] = cachedMeasures{analysisDate: project.AnalysisDate, measures: measures}
+			}
+		}
+		c.measureCacheKeys = strings.Join(withQualityGateKey(c.getNumericMetricKeys(snap.Metrics), snap.Metrics), ",")
+		c.lastResync = snap.TakenAt
+	}
+
+	// Projects whose measures the first refresh fails to fetch keep them in the next snapshot
+	c.snapshotMeasures = snap.Measures
+
+	// Quality gate transitions that happened while the exporter was down are notified by the first refresh
+	if c.notifier != nil {
+		c.gateStatuses = qualityGateStatusesOf(&snap)
+	}
+
+	c.snapshotMu.Lock()
+	c.staleMetrics = metrics
+	c.snapshotMu.Unlock()
+
+	log.Printf("Loaded snapshot of %d projects taken at %s", len(snap.Projects), snap.TakenAt.Format(time.RFC3339))
+	return nil
+}
+
+// exportSnapshot builds the metrics of a snapshot
+func (c *Collector) exportSnapshot(snap *snapshot) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+		done <- metrics
+	}()
+
+	c.exportMetricCatalogSize(ch, snap.Metrics)
+
+	neverAnalyzed := 0
+	for _, project := range snap.Projects {
+		if !c.exportProject(ch, project) {
+			neverAnalyzed++
+		}
+		c.exportMeasures(ch, project, snap.Measures[project.Key], snap.Metrics, time.Time{})
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.neverAnalyzed,
+		prometheus.GaugeValue,
+		float64(neverAnalyzed),
+	)
+
+	close(ch)
+	return <-done
+}
+
+// collectStaleSnapshot serves the snapshot loaded on startup, if the first refresh has not
+// completed yet, and starts that refresh in the background. It returns false once the refresh
+// completed.
+func (c *Collector) collectStaleSnapshot(ch chan<- prometheus.Metric) bool {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+
+	if c.staleMetrics == nil {
+		return false
+	}
+
+	if !c.warmingUp {
+		c.warmingUp = true
+		c.warmUpDone.Add(1)
+		go c.warmUp()
+	}
+
+	for _, metric := range c.staleMetrics {
+		ch <- metric
+	}
+	ch <- prometheus.MustNewConstMetric(c.snapshotStale, prometheus.GaugeValue, 1)
+	c.collectCounters(ch)
+
+	return true
+}
+
+// warmUp runs the first refresh after loading the snapshot, discarding its metrics. If it fails,
+// the snapshot is still served and the refresh is retried on the next scrape.
+func (c *Collector) warmUp() {
+	defer c.warmUpDone.Done()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+
+	c.mu.Lock()
+	complete := c.collect(ch)
+	c.mu.Unlock()
+	close(ch)
+
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+	c.warmingUp = false
+
+	if complete {
+		c.staleMetrics = nil
+		log.Printf("First refresh completed, no longer serving the snapshot")
+	}
+}
+
+// carryOverMeasures fills in the measures of the projects that could not be fetched from the
+// previous snapshot, so that a collection with failed fetches doesn't overwrite them in the file
+func (c *Collector) carryOverMeasures(snap *snapshot) {
+	for _, project := range snap.Projects {
+		if _, fetched := snap.Measures[project.Key]; fetched {
+			continue
+		}
+		if measures, exists := c.snapshotMeasures[project.Key]; exists {
+			snap.Measures[project.Key] = measures
+		}
+	}
+	c.snapshotMeasures = snap.Measures
+}
+
+// saveSnapshot writes the snapshot file. The snapshot is written to a temporary file first, then
+// renamed, so that a crash never leaves a partial snapshot behind.
+func (c *Collector) saveSnapshot(snap *snapshot) {
+	content, err := json.Marshal(snap)
+	if err != nil {
+		log.Printf("Error encoding snapshot: %v", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".*.tmp")
+	if err != nil {
+		log.Printf("Error writing snapshot: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		log.Printf("Error writing snapshot: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("Error writing snapshot: %v", err)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), c.snapshotPath); err != nil {
+		log.Printf("Error writing snapshot: %v", err)
+	}
+}
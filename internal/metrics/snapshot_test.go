@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newGateServer creates a mock SonarQube server with one analyzed project failing its quality
+// gate. Requests wait for the release channel, if any.
+func newGateServer(release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if release != nil {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{
+					{Key: "bugs", Type: "INT", Name: "Bugs"},
+					{Key: "alert_status", Type: "LEVEL", Name: "Quality Gate Status"},
+				},
+				Total: 2,
+			})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging: sonarqube.Paging{Total: 1},
+				Components: []sonarqube.Component{
+					{Key: "project1", Name: "Project 1", Qualifier: "TRK", AnalysisDate: "2024-01-15T10:30:00+0000"},
+				},
+			})
+		case "/api/measures/component":
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key: "project1",
+					Measures: []sonarqube.Measure{
+						{Metric: "bugs", Value: "5"},
+						{Metric: "alert_status", Value: "ERROR"},
+					},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// gather collects the metrics of a collector, by metric family name
+func gather(t *testing.T, collector prometheus.Collector) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+// gaugeValue returns the value of the first series of a gauge family
+func gaugeValue(t *testing.T, families map[string]*dto.MetricFamily, name string) float64 {
+	t.Helper()
+
+	family, exists := families[name]
+	if !exists {
+		t.Fatalf("Expected metric %s to be collected", name)
+	}
+	return family.GetMetric()[0].GetGauge().GetValue()
+}
+
+func TestSnapshot_WarmRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	// A first exporter collects and persists the snapshot
+	server := newGateServer(nil)
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithSnapshot(path))
+
+	families := gather(t, collector)
+	if value := gaugeValue(t, families, "sonarqube_exporter_snapshot_stale"); value != 0 {
+		t.Errorf("Expected live metrics not to be stale, got: %v", value)
+	}
+	server.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected snapshot to be written, got: %v", err)
+	}
+
+	// After a restart, the snapshot is served while SonarQube is slow to answer
+	release := make(chan struct{})
+	server = newGateServer(release)
+	defer server.Close()
+
+	client = sonarqube.NewClient(server.URL, "test-token")
+	collector = NewCollector(client, WithSnapshot(path))
+	if err := collector.LoadSnapshot(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	families = gather(t, collector)
+	if value := gaugeValue(t, families, "sonarqube_exporter_snapshot_stale"); value != 1 {
+		t.Errorf("Expected snapshot metrics to be stale, got: %v", value)
+	}
+	if value := gaugeValue(t, families, "sonarqube_bugs"); value != 5 {
+		t.Errorf("Expected 5 bugs from the snapshot, got: %v", value)
+	}
+	if _, exists := families["sonarqube_project_quality_gate_status"]; !exists {
+		t.Error("Expected quality gate status from the snapshot")
+	}
+
+	// Once the first refresh completes, live metrics are served
+	close(release)
+	collector.warmUpDone.Wait()
+
+	families = gather(t, collector)
+	if value := gaugeValue(t, families, "sonarqube_exporter_snapshot_stale"); value != 0 {
+		t.Errorf("Expected live metrics after the first refresh, got: %v", value)
+	}
+}
+
+func TestSnapshot_FailedMeasures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{{Key: "bugs", Type: "INT", Name: "Bugs"}},
+				Total:   1,
+			})
+		case "/api/components/search_projects":
+			json.NewEncoder(w).Encode(sonarqube.ComponentsResponse{
+				Paging:     sonarqube.Paging{Total: 2},
+				Components: []sonarqube.Component{{Key: "project1"}, {Key: "project2"}},
+			})
+		case "/api/measures/component":
+			component := r.URL.Query().Get("component")
+			if component == "project2" && failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key:      component,
+					Measures: []sonarqube.Measure{{Metric: "bugs", Value: "5"}},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithSnapshot(path))
+	collectCount(collector)
+
+	// The measures of project2 can't be fetched: the snapshot keeps the previous ones
+	failing.Store(true)
+	collectCount(collector)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(content, &snap); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+
+	for _, project := range []string{"project1", "project2"} {
+		if measures := snap.Measures[project]; len(measures) != 1 || measures[0].Value != "5" {
+			t.Errorf("Expected the measures of %s in the snapshot, got: %v", project, measures)
+		}
+	}
+}
+
+func TestLoadSnapshot_Missing(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithSnapshot(filepath.Join(t.TempDir(), "snapshot.json")))
+
+	if err := collector.LoadSnapshot(); err != nil {
+		t.Fatalf("Expected no error without a snapshot file, got: %v", err)
+	}
+	if collector.staleMetrics != nil {
+		t.Error("Expected no snapshot to be served")
+	}
+}
+
+func TestLoadSnapshot_OtherVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(path, []byte(`{"version": 999, "projects": [{"key": "project1"}]}`), 0600); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithSnapshot(path))
+
+	if err := collector.LoadSnapshot(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if collector.staleMetrics != nil {
+		t.Error("Expected snapshot of another version to be ignored")
+	}
+}
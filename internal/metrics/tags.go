@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"log"
+	"strings"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// reservedLabels are the labels already set on measure metrics, which tag labels can't override
+var reservedLabels = map[string]bool{
+	"project_key":  true,
+	"project_name": true,
+	"domain":       true,
+	"metric":       true,
+	"rating":       true,
+}
+
+// WithTagLabels promotes project tags starting with one of the prefixes into labels on every
+// measure. The label name is the prefix without its trailing separator, so the "team:" prefix
+// turns the "team:payments" tag into a team="payments" label.
+func WithTagLabels(prefixes []string) Option {
+	return func(c *Collector) {
+		c.tagPrefixes = nil
+		c.tagLabelKeys = nil
+		seen := make(map[string]bool)
+
+		for _, prefix := range prefixes {
+			name, ok := tagLabelName(prefix)
+			if !ok || seen[name] {
+				log.Printf("Warning: ignoring tag prefix %q, it doesn't map to a valid and unique label name", prefix)
+				continue
+			}
+			seen[name] = true
+
+			c.tagPrefixes = append(c.tagPrefixes, prefix)
+			c.tagLabelKeys = append(c.tagLabelKeys, name)
+		}
+	}
+}
+
+// tagLabelName derives the label name of a tag prefix
+func tagLabelName(prefix string) (string, bool) {
+	name := strings.TrimRightFunc(prefix, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	})
+	name = sanitizeMetricName(name)
+
+	if name == "" || strings.HasPrefix(name, "__") || reservedLabels[name] || !model.LabelName(name).IsValidLegacy() {
+		return "", false
+	}
+	return name, true
+}
+
+// tagLabelValues returns the values of the tag labels for a project. When several tags match
+// a prefix, the first one wins; a project without a matching tag gets an empty value.
+func (c *Collector) tagLabelValues(project sonarqube.Component) []string {
+	if len(c.tagPrefixes) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(c.tagPrefixes))
+	for i, prefix := range c.tagPrefixes {
+		for _, tag := range project.Tags {
+			if len(tag) > len(prefix) && strings.HasPrefix(tag, prefix) {
+				values[i] = tag[len(prefix):]
+				break
+			}
+		}
+	}
+
+	return values
+}
+
+// exportTags exports one info series per project tag
+func (c *Collector) exportTags(ch chan<- prometheus.Metric, project sonarqube.Component) {
+	for _, tag := range project.Tags {
+		ch <- prometheus.MustNewConstMetric(
+			c.projectTag,
+			prometheus.GaugeValue,
+			1,
+			project.Key,
+			tag,
+		)
+	}
+}
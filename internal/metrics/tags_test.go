@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTagLabelName(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected string
+		ok       bool
+	}{
+		{prefix: "team:", expected: "team", ok: true},
+		{prefix: "tier-", expected: "tier", ok: true},
+		{prefix: "Business.Unit=", expected: "business_unit", ok: true},
+		{prefix: "project_key:", ok: false},
+		{prefix: "::", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			name, ok := tagLabelName(tt.prefix)
+			if ok != tt.ok || name != tt.expected {
+				t.Errorf("Expected (%q, %v), got: (%q, %v)", tt.expected, tt.ok, name, ok)
+			}
+		})
+	}
+}
+
+func TestWithTagLabels(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithTagLabels([]string{"team:", "tier:", "team-", "domain:"}))
+
+	// team- duplicates the team label and domain is reserved
+	if len(collector.tagLabelKeys) != 2 || collector.tagLabelKeys[0] != "team" || collector.tagLabelKeys[1] != "tier" {
+		t.Fatalf("Expected tag labels [team tier], got: %v", collector.tagLabelKeys)
+	}
+
+	project := sonarqube.Component{
+		Key:  "project1",
+		Name: "Project 1",
+		Tags: []string{"java", "team:payments", "team:billing"},
+	}
+
+	values := collector.tagLabelValues(project)
+	if len(values) != 2 || values[0] != "payments" || values[1] != "" {
+		t.Errorf("Expected tag label values [payments ''], got: %q", values)
+	}
+}
+
+func TestExportMeasure_TagLabels(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client, WithTagLabels([]string{"team:"}))
+
+	allMetrics := []sonarqube.Metric{
+		{Key: "bugs", Type: "INT", Description: "Number of bugs", Domain: "Reliability"},
+	}
+	project := sonarqube.Component{Key: "project1", Name: "Project 1", Tags: []string{"team:payments"}}
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.exportMeasure(ch, project, sonarqube.Measure{Metric: "bugs", Value: "3"}, allMetrics, time.Time{})
+	close(ch)
+
+	m := <-ch
+	if m == nil {
+		t.Fatal("Expected a metric to be exported")
+	}
+
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+
+	found := false
+	for _, label := range pb.GetLabel() {
+		if label.GetName() == "team" && label.GetValue() == "payments" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected team=\"payments\" label, got: %v", pb.GetLabel())
+	}
+}
+
+func TestExportTags(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := NewCollector(client)
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.exportTags(ch, sonarqube.Component{Key: "project1", Tags: []string{"java", "team:payments"}})
+	close(ch)
+
+	count := 0
+	for range ch {
+		count++
+	}
+
+	if count != 2 {
+		t.Errorf("Expected 2 tag metrics, got: %d", count)
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"errors"
+	"log"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithTokenMonitoring enables the validity and expiration metrics of the exporter's own token.
+// SonarQube doesn't tell which of the user tokens is in use, so it is looked up by name, or is
+// the only token of the user when the name is empty.
+func WithTokenMonitoring(enabled bool, tokenName string) Option {
+	return func(c *Collector) {
+		c.tokenMonitoring = enabled
+		c.tokenName = tokenName
+	}
+}
+
+// collectToken exports the validity and the expiration date of the exporter's token
+func (c *Collector) collectToken(ch chan<- prometheus.Metric) {
+	valid, err := c.client.ValidateAuthentication()
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		c.recordError(err)
+
+		// Depending on its configuration, SonarQube rejects an invalid token instead of reporting it
+		if !errors.Is(err, sonarqube.ErrUnauthorized) {
+			return
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.tokenValid, prometheus.GaugeValue, boolToFloat(valid))
+	if !valid {
+		log.Printf("Error: the SonarQube token is invalid or expired")
+		return
+	}
+
+	tokens, err := c.client.GetUserTokens()
+	if err != nil {
+		log.Printf("Error fetching user tokens: %v", err)
+		c.recordError(err)
+		return
+	}
+
+	token, ok := c.findToken(tokens)
+	if !ok || token.ExpirationDate == "" {
+		return
+	}
+
+	expiresAt, err := parseDate(token.ExpirationDate)
+	if err != nil {
+		log.Printf("Error parsing expiration date of token %s: %v", token.Name, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.tokenExpiration,
+		prometheus.GaugeValue,
+		float64(expiresAt.Unix()),
+		token.Name,
+	)
+}
+
+// findToken finds the exporter's token among the user tokens, logging once when it can't
+func (c *Collector) findToken(tokens []sonarqube.UserToken) (sonarqube.UserToken, bool) {
+	if c.tokenName == "" && len(tokens) == 1 {
+		return tokens[0], true
+	}
+
+	for _, token := range tokens {
+		if c.tokenName != "" && token.Name == c.tokenName {
+			return token, true
+		}
+	}
+
+	if !c.tokenNotFound {
+		log.Printf("Warning: can't identify the exporter's token among the %d user tokens, set its name to monitor its expiration", len(tokens))
+		c.tokenNotFound = true
+	}
+	return sonarqube.UserToken{}, false
+}
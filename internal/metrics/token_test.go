@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newTokenServer creates a mock SonarQube server answering the token endpoints, or failing them
+// with the given status code if not zero
+func newTokenServer(valid bool, status int, tokens []sonarqube.UserToken) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/authentication/validate":
+			json.NewEncoder(w).Encode(sonarqube.AuthenticationResponse{Valid: valid})
+		case "/api/user_tokens/search":
+			json.NewEncoder(w).Encode(sonarqube.UserTokensResponse{Login: "exporter", UserTokens: tokens})
+		}
+	}))
+}
+
+func TestCollectToken(t *testing.T) {
+	tokens := []sonarqube.UserToken{
+		{Name: "ci", ExpirationDate: "2030-06-01T00:00:00+0000"},
+		{Name: "prometheus", ExpirationDate: "2030-01-31T00:00:00+0000"},
+	}
+
+	tests := []struct {
+		name          string
+		valid         bool
+		status        int
+		tokenName     string
+		expectedCount int
+	}{
+		{name: "valid named token", valid: true, tokenName: "prometheus", expectedCount: 2},
+		{name: "valid unidentified token", valid: true, tokenName: "", expectedCount: 1},
+		{name: "invalid token", valid: false, tokenName: "prometheus", expectedCount: 1},
+		{name: "rejected token", valid: false, status: http.StatusUnauthorized, tokenName: "prometheus", expectedCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTokenServer(tt.valid, tt.status, tokens)
+			defer server.Close()
+
+			client := sonarqube.NewClient(server.URL, "test-token")
+			collector := NewCollector(client, WithTokenMonitoring(true, tt.tokenName))
+
+			ch := make(chan prometheus.Metric, 10)
+			collector.collectToken(ch)
+			close(ch)
+
+			count := 0
+			for m := range ch {
+				count++
+
+				var pb dto.Metric
+				if err := m.Write(&pb); err != nil {
+					t.Fatalf("Failed to write metric: %v", err)
+				}
+
+				switch m.Desc() {
+				case collector.tokenValid:
+					if pb.GetGauge().GetValue() != boolToFloat(tt.valid) {
+						t.Errorf("Expected validity %v, got: %v", tt.valid, pb.GetGauge().GetValue())
+					}
+				case collector.tokenExpiration:
+					// 2030-01-31T00:00:00Z
+					if pb.GetGauge().GetValue() != 1896048000 {
+						t.Errorf("Expected expiration of the prometheus token, got: %v", pb.GetGauge().GetValue())
+					}
+				}
+			}
+
+			if count != tt.expectedCount {
+				t.Errorf("Expected %d metrics, got: %d", tt.expectedCount, count)
+			}
+		})
+	}
+}
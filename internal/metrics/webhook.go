@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// webhookSuccessStatus is the status of the webhooks of successful analyses
+const webhookSuccessStatus = "SUCCESS"
+
+// maxConcurrentRefreshes is the number of projects refreshed at once after their webhooks
+const maxConcurrentRefreshes = 4
+
+// WithAnalysisWebhooks enables the sonarqube_analyses_total counter of the analyses notified by
+// SonarQube webhooks. With the incremental refresh, the measures and quality gate status of the
+// analyzed project are also fetched as soon as the webhook is received, ahead of the next scrape,
+// and the projects are only listed again at the full resync.
+func WithAnalysisWebhooks(enabled bool) Option {
+	return func(c *Collector) {
+		c.analysisWebhooks = enabled
+		c.webhookPending = make(map[string]string)
+		c.webhookSlots = make(chan struct{}, maxConcurrentRefreshes)
+	}
+}
+
+// HandleWebhook counts the analysis notified by a SonarQube webhook and, for a successful analysis
+// of the main branch, refreshes the measures of the project in the background
+func (c *Collector) HandleWebhook(payload sonarqube.WebhookPayload) {
+	c.analyses.WithLabelValues(payload.Project.Key, payload.Status).Inc()
+
+	// Only the main branch is exported, and failed analyses don't change the measures
+	if !c.incrementalRefresh || payload.Status != webhookSuccessStatus || !payload.IsMainBranch() {
+		return
+	}
+
+	c.webhookMu.Lock()
+	defer c.webhookMu.Unlock()
+
+	// A project already waiting for or being refreshed is refreshed with its latest analysis
+	_, pending := c.webhookPending[payload.Project.Key]
+	c.webhookPending[payload.Project.Key] = payload.AnalysedAt
+	if pending {
+		return
+	}
+
+	c.webhookRefresh.Add(1)
+	go c.refreshPendingProject(payload.Project.Key)
+}
+
+// refreshPendingProject refreshes a project notified by webhooks until its latest analysis is
+// refreshed, at most maxConcurrentRefreshes projects at once
+func (c *Collector) refreshPendingProject(projectKey string) {
+	defer c.webhookRefresh.Done()
+
+	c.webhookSlots <- struct{}{}
+	defer func() { <-c.webhookSlots }()
+
+	for {
+		c.webhookMu.Lock()
+		analysisDate := c.webhookPending[projectKey]
+		c.webhookMu.Unlock()
+
+		c.refreshProject(projectKey, analysisDate)
+
+		// Another analysis may have been notified during the refresh
+		c.webhookMu.Lock()
+		refreshed := c.webhookPending[projectKey] == analysisDate
+		if refreshed {
+			delete(c.webhookPending, projectKey)
+		}
+		c.webhookMu.Unlock()
+
+		if refreshed {
+			return
+		}
+	}
+}
+
+// refreshProject fetches the measures of a project analyzed at the given date into the measure
+// cache, and updates its analysis date in the project cache
+func (c *Collector) refreshProject(projectKey, analysisDate string) {
+	metricKeys, measures, err := c.fetchProjectMeasures(projectKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Even without its measures, the next scrape then sees the project was re-analyzed
+	c.updateProjectCache(projectKey, analysisDate)
+	if err != nil {
+		return
+	}
+
+	// The cache is emptied when the fetched metrics change, the next scrape then fetches the project
+	if strings.Join(metricKeys, ",") != c.measureCacheKeys {
+		return
+	}
+
+	c.measureCache[projectKey] = cachedMeasures{analysisDate: analysisDate, measures: measures}
+	log.Printf("Refreshed measures of project %s after its analysis", projectKey)
+}
+
+// fetchProjectMeasures fetches the measures of a project, and returns them with the metrics fetched
+func (c *Collector) fetchProjectMeasures(projectKey string) ([]string, []sonarqube.Measure, error) {
+	metrics, err := c.metricCatalog()
+	if err != nil {
+		log.Printf("Error fetching metrics: %v", err)
+		c.recordError(err)
+		return nil, nil, err
+	}
+	metricKeys := withQualityGateKey(c.getNumericMetricKeys(metrics), metrics)
+
+	measures, err := c.client.GetProjectMeasures(projectKey, metricKeys)
+	if err != nil {
+		log.Printf("Error fetching measures for project %s: %v", projectKey, err)
+		c.recordError(err)
+		return nil, nil, err
+	}
+
+	return metricKeys, measures, nil
+}
+
+// getProjects returns the projects to collect. With the incremental refresh, webhooks keep the
+// analysis dates of the listed projects up to date, so the projects are only listed again at the
+// full resync, or after the webhook of a project missing from the list. Without a full resync, they
+// are listed at every collection, to drop the deleted projects.
+func (c *Collector) getProjects() ([]sonarqube.Component, error) {
+	if !c.analysisWebhooks || !c.incrementalRefresh || c.resyncInterval <= 0 {
+		return c.client.GetProjects()
+	}
+
+	if c.projectCache != nil && c.now().Sub(c.lastResync) < c.resyncInterval {
+		return c.projectCache, nil
+	}
+
+	// A truncated list is not cached, the next collection lists the projects again
+	projects, err := c.client.GetProjects()
+	c.projectCache = nil
+	if err == nil {
+		c.projectCache = projects
+	}
+	return projects, err
+}
+
+// updateProjectCache sets the analysis date of a project in the project cache. If the project is
+// missing, e.g. a project analyzed for the first time, the cache is emptied for the next collection
+// to list the projects again.
+func (c *Collector) updateProjectCache(projectKey, analysisDate string) {
+	if c.projectCache == nil {
+		return
+	}
+
+	i := slices.IndexFunc(c.projectCache, func(project sonarqube.Component) bool {
+		return project.Key == projectKey
+	})
+	if i < 0 || analysisDate == "" {
+		c.projectCache = nil
+		return
+	}
+
+	// The cached list may be held by the snapshot of a previous collection, so it is copied
+	projects := slices.Clone(c.projectCache)
+	projects[i].AnalysisDate = analysisDate
+	c.projectCache = projects
+}
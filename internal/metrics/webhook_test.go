@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+func TestHandleWebhook(t *testing.T) {
+	project1 := sonarqube.Component{Key: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"}
+	project2 := sonarqube.Component{Key: "project2", AnalysisDate: "2024-01-10T08:00:00+0000"}
+
+	server := newFleetServer(project1, project2)
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithIncrementalRefresh(true, time.Hour), WithAnalysisWebhooks(true))
+
+	collectCount(collector)
+	server.takeMeasureRequests()
+
+	// project1 is re-analyzed: the webhook refreshes it before the next scrape
+	project1.AnalysisDate = "2024-01-16T09:00:00+0000"
+	server.setProjects(project1, project2)
+
+	collector.HandleWebhook(sonarqube.WebhookPayload{
+		Status:     "SUCCESS",
+		AnalysedAt: "2024-01-16T10:00:00+0100",
+		Project:    sonarqube.WebhookProject{Key: "project1"},
+	})
+	collector.webhookRefresh.Wait()
+
+	if requests := server.takeMeasureRequests(); len(requests) != 1 || requests["project1"] != 1 {
+		t.Errorf("Expected the webhook to fetch the measures of project1, got: %v", requests)
+	}
+
+	// The next scrape finds the project up to date in the cache
+	collectCount(collector)
+	if requests := server.takeMeasureRequests(); len(requests) != 0 {
+		t.Errorf("Expected no measure requests after the webhook refresh, got: %v", requests)
+	}
+
+	// Analyses of other branches and failed ones are only counted
+	collector.HandleWebhook(sonarqube.WebhookPayload{
+		Status:  "SUCCESS",
+		Project: sonarqube.WebhookProject{Key: "project1"},
+		Branch:  &sonarqube.WebhookBranch{Name: "feature", Type: "BRANCH"},
+	})
+	collector.HandleWebhook(sonarqube.WebhookPayload{
+		Status:  "FAILED",
+		Project: sonarqube.WebhookProject{Key: "project2"},
+	})
+	collector.webhookRefresh.Wait()
+
+	if requests := server.takeMeasureRequests(); len(requests) != 0 {
+		t.Errorf("Expected no measure requests for other branches and failed analyses, got: %v", requests)
+	}
+
+	families := gather(t, collector)
+	counts := make(map[string]float64)
+	for _, metric := range families["sonarqube_analyses_total"].GetMetric() {
+		key := ""
+		for _, label := range metric.GetLabel() {
+			key += label.GetValue() + "/"
+		}
+		counts[key] = metric.GetCounter().GetValue()
+	}
+
+	if counts["project1/SUCCESS/"] != 2 || counts["project2/FAILED/"] != 1 {
+		t.Errorf("Expected 2 successful analyses of project1 and 1 failed of project2, got: %v", counts)
+	}
+}
+
+func TestHandleWebhook_ProjectCache(t *testing.T) {
+	project1 := sonarqube.Component{Key: "project1", AnalysisDate: "2024-01-15T10:30:00+0000"}
+	project2 := sonarqube.Component{Key: "project2", AnalysisDate: "2024-01-10T08:00:00+0000"}
+
+	server := newFleetServer(project1, project2)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC)
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithIncrementalRefresh(true, time.Hour), WithAnalysisWebhooks(true))
+	collector.now = func() time.Time { return now }
+
+	collectCount(collector)
+	if requests := server.takeProjectRequests(); requests != 1 {
+		t.Fatalf("Expected the first scrape to list the projects, got %d searches", requests)
+	}
+	server.takeMeasureRequests()
+
+	// Between full resyncs, the projects are not listed again
+	collectCount(collector)
+	if requests := server.takeProjectRequests(); requests != 0 {
+		t.Errorf("Expected no project search before the full resync, got: %d", requests)
+	}
+
+	// The webhook updates the analysis date of project1 without a project search
+	collector.HandleWebhook(sonarqube.WebhookPayload{
+		Status:     "SUCCESS",
+		AnalysedAt: "2024-01-16T10:00:00+0100",
+		Project:    sonarqube.WebhookProject{Key: "project1"},
+	})
+	collector.webhookRefresh.Wait()
+	server.takeMeasureRequests()
+
+	families := gather(t, collector)
+	if requests := server.takeProjectRequests(); requests != 0 {
+		t.Errorf("Expected no project search after the webhook, got: %d", requests)
+	}
+	if requests := server.takeMeasureRequests(); len(requests) != 0 {
+		t.Errorf("Expected no measure requests after the webhook refresh, got: %v", requests)
+	}
+	analyzed := 0.0
+	for _, metric := range families["sonarqube_project_last_analysis_timestamp_seconds"].GetMetric() {
+		if metric.GetLabel()[0].GetValue() == "project1" {
+			analyzed = metric.GetGauge().GetValue()
+		}
+	}
+	if analyzed != float64(now.Unix()) {
+		t.Errorf("Expected the analysis date of project1 from the webhook, got: %v", analyzed)
+	}
+
+	// The webhook of a new project lists the projects again
+	project3 := sonarqube.Component{Key: "project3", AnalysisDate: "2024-01-16T09:30:00+0000"}
+	server.setProjects(project1, project2, project3)
+	collector.HandleWebhook(sonarqube.WebhookPayload{
+		Status:     "SUCCESS",
+		AnalysedAt: project3.AnalysisDate,
+		Project:    sonarqube.WebhookProject{Key: "project3"},
+	})
+	collector.webhookRefresh.Wait()
+
+	collectCount(collector)
+	if requests := server.takeProjectRequests(); requests != 1 {
+		t.Errorf("Expected the webhook of a new project to list the projects again, got %d searches", requests)
+	}
+
+	// So does the full resync
+	now = now.Add(time.Hour)
+	collectCount(collector)
+	if requests := server.takeProjectRequests(); requests != 1 {
+		t.Errorf("Expected the full resync to list the projects again, got %d searches", requests)
+	}
+}
+
+func TestHandleWebhook_PendingRefreshes(t *testing.T) {
+	started := make(chan string, 20)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/metrics/search":
+			json.NewEncoder(w).Encode(sonarqube.MetricsResponse{
+				Metrics: []sonarqube.Metric{{Key: "bugs", Type: "INT", Name: "Bugs"}},
+				Total:   1,
+			})
+		case "/api/measures/component":
+			component := r.URL.Query().Get("component")
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			requests[component]++
+			mu.Unlock()
+
+			started <- component
+			<-release
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			json.NewEncoder(w).Encode(sonarqube.MeasuresResponse{
+				Component: sonarqube.ComponentMeasures{
+					Key:      component,
+					Measures: []sonarqube.Measure{{Metric: "bugs", Value: "5"}},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := sonarqube.NewClient(server.URL, "test-token")
+	collector := NewCollector(client, WithIncrementalRefresh(true, time.Hour), WithAnalysisWebhooks(true))
+	collector.measureCacheKeys = "bugs"
+
+	webhook := func(projectKey, analysedAt string) {
+		collector.HandleWebhook(sonarqube.WebhookPayload{
+			Status:     "SUCCESS",
+			AnalysedAt: analysedAt,
+			Project:    sonarqube.WebhookProject{Key: projectKey},
+		})
+	}
+
+	// project1 is analyzed twice more while its first refresh is running
+	webhook("project1", "2024-01-16T10:00:00+0000")
+	<-started
+	webhook("project1", "2024-01-16T10:05:00+0000")
+	webhook("project1", "2024-01-16T10:10:00+0000")
+
+	// A burst of webhooks of other projects is refreshed a few projects at a time
+	for i := 2; i <= 10; i++ {
+		webhook(fmt.Sprintf("project%d", i), "2024-01-16T10:00:00+0000")
+	}
+
+	close(release)
+	collector.webhookRefresh.Wait()
+
+	if requests["project1"] != 2 {
+		t.Errorf("Expected project1 to be refreshed once more for its pending analyses, got %d refreshes", requests["project1"])
+	}
+	for i := 2; i <= 10; i++ {
+		if project := fmt.Sprintf("project%d", i); requests[project] != 1 {
+			t.Errorf("Expected %s to be refreshed once, got %d refreshes", project, requests[project])
+		}
+	}
+	if maxInFlight > maxConcurrentRefreshes {
+		t.Errorf("Expected at most %d refreshes at once, got: %d", maxConcurrentRefreshes, maxInFlight)
+	}
+	if date := collector.measureCache["project1"].analysisDate; date != "2024-01-16T10:10:00+0000" {
+		t.Errorf("Expected project1 to be cached with its latest analysis, got: %s", date)
+	}
+	if len(collector.webhookPending) != 0 {
+		t.Errorf("Expected no pending refreshes, got: %v", collector.webhookPending)
+	}
+}
+
+func TestSameAnalysisDate(t *testing.T) {
+	if !sameAnalysisDate("2024-01-16T09:00:00+0000", "2024-01-16T10:00:00+0100") {
+		t.Error("Expected dates of the same instant in different time zones to match")
+	}
+	if sameAnalysisDate("2024-01-16T09:00:00+0000", "2024-01-16T09:00:01+0000") {
+		t.Error("Expected different dates not to match")
+	}
+	if sameAnalysisDate("invalid", "") {
+		t.Error("Expected invalid dates not to match")
+	}
+}
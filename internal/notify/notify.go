@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notification is a quality gate transition of a project
+type Notification struct {
+	ProjectKey     string      `json:"projectKey"`
+	ProjectName    string      `json:"projectName"`
+	Branch         string      `json:"branch,omitempty"`
+	PreviousStatus string      `json:"previousStatus"`
+	Status         string      `json:"status"`
+	Conditions     []Condition `json:"conditions"`
+	DashboardURL   string      `json:"dashboardUrl"`
+}
+
+// Condition is a failing condition of a quality gate
+type Condition struct {
+	Metric         string `json:"metric"`
+	Comparator     string `json:"comparator"`
+	ErrorThreshold string `json:"errorThreshold,omitempty"`
+	ActualValue    string `json:"actualValue,omitempty"`
+	Status         string `json:"status"`
+}
+
+// Notifier POSTs the notifications of quality gate transitions to URLs
+type Notifier struct {
+	urls       []string
+	template   *template.Template
+	httpClient *http.Client
+}
+
+// Option configures optional behaviour of the Notifier
+type Option func(*Notifier)
+
+// WithTemplate renders the body of the notifications with a text/template executed on the
+// Notification, instead of the Slack-compatible body
+func WithTemplate(tmpl *template.Template) Option {
+	return func(n *Notifier) {
+		n.template = tmpl
+	}
+}
+
+// New creates a notifier POSTing notifications to the URLs
+func New(urls []string, opts ...Option) *Notifier {
+	n := &Notifier{
+		urls: urls,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// LoadTemplate parses a text/template file used as the body of the notifications. The json
+// function quotes a value as a JSON string, e.g. {{json .ProjectName}}.
+func LoadTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification template: %w", err)
+	}
+
+	tmpl, err := template.New("notification").Funcs(template.FuncMap{"json": jsonString}).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template %s: %w", path, err)
+	}
+
+	return tmpl, nil
+}
+
+// Notify POSTs a notification to every URL. It returns the errors of the URLs that failed.
+func (n *Notifier) Notify(notification Notification) error {
+	body, err := n.body(notification)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, target := range n.urls {
+		if err := n.post(target, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// body renders the body of a notification, with the template if any
+func (n *Notifier) body(notification Notification) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(slackMessage{Text: slackText(notification)})
+	}
+
+	var buf bytes.Buffer
+	if err := n.template.Execute(&buf, notification); err != nil {
+		return nil, fmt.Errorf("failed to render notification template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends a notification body to a URL. Errors only name the host of the URL, since
+// webhook URLs usually carry a secret in their path.
+func (n *Notifier) post(rawURL string, body []byte) error {
+	req, err := http.NewRequest("POST", rawURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid notification URL: %w", errors.Unwrap(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send notification to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification rejected by %s with status %d: %s", req.URL.Host, resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+// slackMessage is the body of a Slack incoming webhook, also accepted by Mattermost and Rocket.Chat
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackText formats a notification as Slack mrkdwn
+func slackText(notification Notification) string {
+	var text strings.Builder
+
+	project := notification.ProjectName
+	if project == "" {
+		project = notification.ProjectKey
+	}
+	fmt.Fprintf(&text, "Quality gate of *%s*", project)
+	if notification.Branch != "" {
+		fmt.Fprintf(&text, " (%s)", notification.Branch)
+	}
+	fmt.Fprintf(&text, " changed from %s to *%s*", notification.PreviousStatus, notification.Status)
+
+	for _, condition := range notification.Conditions {
+		fmt.Fprintf(&text, "\n• %s is %s (%s %s)", condition.Metric, condition.ActualValue, condition.Comparator, condition.ErrorThreshold)
+	}
+
+	if notification.DashboardURL != "" {
+		fmt.Fprintf(&text, "\n<%s|Open in SonarQube>", notification.DashboardURL)
+	}
+
+	return text.String()
+}
+
+// jsonString quotes a value as a JSON string, for templated bodies
+func jsonString(value string) (string, error) {
+	quoted, err := json.Marshal(value)
+	return string(quoted), err
+}
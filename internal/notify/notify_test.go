@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// receiver records the bodies POSTed to it, and answers with the given status code
+func receiver(t *testing.T, status int, bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got: %s", r.Method)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, got: %s", r.Header.Get("Content-Type"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+		w.WriteHeader(status)
+	}))
+}
+
+var regression = Notification{
+	ProjectKey:     "project1",
+	ProjectName:    "Project 1",
+	Branch:         "main",
+	PreviousStatus: "OK",
+	Status:         "ERROR",
+	Conditions: []Condition{
+		{Metric: "new_coverage", Comparator: "LT", ErrorThreshold: "80", ActualValue: "42.5", Status: "ERROR"},
+	},
+	DashboardURL: "https://sonar.example.com/dashboard?id=project1",
+}
+
+func TestNotify_Slack(t *testing.T) {
+	var bodies []string
+	server := receiver(t, http.StatusOK, &bodies)
+	defer server.Close()
+
+	notifier := New([]string{server.URL, server.URL})
+	if err := notifier.Notify(regression); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected the notification to be sent to both URLs, got: %d", len(bodies))
+	}
+
+	var message slackMessage
+	if err := json.Unmarshal([]byte(bodies[0]), &message); err != nil {
+		t.Fatalf("Expected a JSON body, got: %v", err)
+	}
+
+	for _, expected := range []string{"*Project 1* (main)", "from OK to *ERROR*", "new_coverage is 42.5 (LT 80)", "<https://sonar.example.com/dashboard?id=project1|Open in SonarQube>"} {
+		if !strings.Contains(message.Text, expected) {
+			t.Errorf("Expected text to contain %q, got: %s", expected, message.Text)
+		}
+	}
+}
+
+func TestNotify_Template(t *testing.T) {
+	var bodies []string
+	server := receiver(t, http.StatusOK, &bodies)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "notification.tmpl")
+	content := `{"project": {{json .ProjectName}}, "status": "{{.Status}}", "failing": [{{range $i, $c := .Conditions}}{{if $i}},{{end}}{{json $c.Metric}}{{end}}]}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	notifier := New([]string{server.URL}, WithTemplate(tmpl))
+	if err := notifier.Notify(regression); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := `{"project": "Project 1", "status": "ERROR", "failing": ["new_coverage"]}`
+	if len(bodies) != 1 || bodies[0] != expected {
+		t.Errorf("Expected body %s, got: %v", expected, bodies)
+	}
+}
+
+func TestNotify_Rejected(t *testing.T) {
+	var bodies []string
+	failing := receiver(t, http.StatusBadRequest, &bodies)
+	defer failing.Close()
+	working := receiver(t, http.StatusOK, &bodies)
+	defer working.Close()
+
+	notifier := New([]string{failing.URL, working.URL})
+	err := notifier.Notify(regression)
+
+	if err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Expected the rejection to be reported, got: %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("Expected the notification to still be sent to the other URL, got: %d", len(bodies))
+	}
+}
+
+func TestLoadTemplate_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Status"), 0600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	if _, err := LoadTemplate(path); err == nil {
+		t.Error("Expected error for an invalid template, got nil")
+	}
+	if _, err := LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Expected error for a missing template, got nil")
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushOptions configures the push of the metrics to a Pushgateway
+type PushOptions struct {
+	URL              string
+	Job              string
+	Instance         string
+	Username         string
+	Password         string
+	Interval         time.Duration
+	Timeout          time.Duration
+	DeleteOnShutdown bool
+}
+
+// Pusher pushes the metrics of a registry to a Pushgateway on an interval, for Prometheus servers
+// that can't reach the exporter
+type Pusher struct {
+	pusher           *push.Pusher
+	url              string
+	interval         time.Duration
+	deleteOnShutdown bool
+}
+
+// NewPusher creates a pusher of the metrics gathered from the registry, grouped by job and instance
+func NewPusher(gatherer prometheus.Gatherer, opts PushOptions) *Pusher {
+	pusher := push.New(opts.URL, opts.Job).
+		Gatherer(gatherer).
+		Client(&http.Client{Timeout: opts.Timeout})
+
+	if opts.Instance != "" {
+		pusher = pusher.Grouping("instance", opts.Instance)
+	}
+	if opts.Username != "" {
+		pusher = pusher.BasicAuth(opts.Username, opts.Password)
+	}
+
+	return &Pusher{
+		pusher:           pusher,
+		url:              opts.URL,
+		interval:         opts.Interval,
+		deleteOnShutdown: opts.DeleteOnShutdown,
+	}
+}
+
+// Run pushes the metrics right away, then on every interval until the context is done. The
+// metrics of the group are then deleted from the Pushgateway, if enabled.
+func (p *Pusher) Run(ctx context.Context) {
+	log.Printf("Pushing metrics to %s every %s", p.url, p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.push(ctx)
+
+		select {
+		case <-ctx.Done():
+			p.delete()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// push replaces the metrics of the group on the Pushgateway with the gathered ones
+func (p *Pusher) push(ctx context.Context) {
+	if err := p.pusher.PushContext(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Error pushing metrics: %v", err)
+	}
+}
+
+// delete deletes the metrics of the group from the Pushgateway, if enabled
+func (p *Pusher) delete() {
+	if !p.deleteOnShutdown {
+		return
+	}
+
+	if err := p.pusher.Delete(); err != nil {
+		log.Printf("Error deleting metrics from the Pushgateway: %v", err)
+		return
+	}
+	log.Printf("Deleted metrics from the Pushgateway")
+}
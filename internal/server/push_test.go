@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushgateway is a Pushgateway stand-in recording the requests it receives
+type pushgateway struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []pushRequest
+	pushed   chan struct{}
+}
+
+type pushRequest struct {
+	method string
+	path   string
+	user   string
+	body   string
+}
+
+func newPushgateway() *pushgateway {
+	g := &pushgateway{pushed: make(chan struct{}, 10)}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		user, _, _ := r.BasicAuth()
+
+		g.mu.Lock()
+		g.requests = append(g.requests, pushRequest{method: r.Method, path: r.URL.Path, user: user, body: string(body)})
+		g.mu.Unlock()
+
+		if r.Method == http.MethodPut {
+			g.pushed <- struct{}{}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return g
+}
+
+func (g *pushgateway) recorded() []pushRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]pushRequest(nil), g.requests...)
+}
+
+func TestPusher(t *testing.T) {
+	gateway := newPushgateway()
+	defer gateway.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "sonarqube_projects_never_analyzed", Help: "Test gauge"})
+	gauge.Set(3)
+	registry.MustRegister(gauge)
+
+	pusher := NewPusher(registry, PushOptions{
+		URL:              gateway.URL,
+		Job:              "sonarqube_exporter",
+		Instance:         "exporter-1",
+		Username:         "pusher",
+		Password:         "secret",
+		Interval:         10 * time.Millisecond,
+		Timeout:          time.Second,
+		DeleteOnShutdown: true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pusher.Run(ctx)
+		close(done)
+	}()
+
+	// Metrics are pushed right away, then on every interval
+	for i := 0; i < 2; i++ {
+		select {
+		case <-gateway.pushed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected push %d, got none", i+1)
+		}
+	}
+
+	cancel()
+	<-done
+
+	requests := gateway.recorded()
+	if len(requests) < 3 {
+		t.Fatalf("Expected at least 2 pushes and a delete, got: %d requests", len(requests))
+	}
+
+	const path = "/metrics/job/sonarqube_exporter/instance/exporter-1"
+	for _, req := range requests {
+		if req.path != path {
+			t.Errorf("Expected path %s, got: %s", path, req.path)
+		}
+		if req.user != "pusher" {
+			t.Errorf("Expected basic auth user 'pusher', got: '%s'", req.user)
+		}
+	}
+
+	if first := requests[0]; first.method != http.MethodPut || !strings.Contains(first.body, "sonarqube_projects_never_analyzed") {
+		t.Errorf("Expected the metrics to be pushed with PUT, got: %s %q", first.method, first.body)
+	}
+	if last := requests[len(requests)-1]; last.method != http.MethodDelete {
+		t.Errorf("Expected the metrics to be deleted on shutdown, got: %s", last.method)
+	}
+}
+
+func TestPusher_KeepOnShutdown(t *testing.T) {
+	gateway := newPushgateway()
+	defer gateway.Close()
+
+	pusher := NewPusher(prometheus.NewRegistry(), PushOptions{
+		URL:      gateway.URL,
+		Job:      "sonarqube_exporter",
+		Interval: time.Hour,
+		Timeout:  time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pusher.Run(ctx)
+		close(done)
+	}()
+
+	<-gateway.pushed
+	cancel()
+	<-done
+
+	requests := gateway.recorded()
+	if len(requests) != 1 || requests[0].path != "/metrics/job/sonarqube_exporter" {
+		t.Errorf("Expected a single push without instance grouping and no delete, got: %+v", requests)
+	}
+}
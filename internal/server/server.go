@@ -14,13 +14,33 @@ import (
 type Server struct {
 	httpServer *http.Server
 	registry   *prometheus.Registry
+
+	// Optional SonarQube webhook receiver
+	webhookSecret   string
+	webhookReceiver WebhookReceiver
+}
+
+// Option configures optional behaviour of the Server
+type Option func(*Server)
+
+// WithWebhook enables the /webhook endpoint, which passes the analysis webhooks of SonarQube
+// signed with the secret to the receiver
+func WithWebhook(secret string, receiver WebhookReceiver) Option {
+	return func(s *Server) {
+		s.webhookSecret = secret
+		s.webhookReceiver = receiver
+	}
 }
 
 // New creates a new HTTP server
-func New(address string, collector prometheus.Collector) *Server {
+func New(address string, collector prometheus.Collector, opts ...Option) *Server {
+	s := &Server{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
 	// Create a new Prometheus registry
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(collector)
+	registry := NewRegistry(collector)
 
 	// Create HTTP mux
 	mux := http.NewServeMux()
@@ -33,19 +53,36 @@ func New(address string, collector prometheus.Collector) *Server {
 	// Add health check endpoint
 	mux.HandleFunc("/health", healthHandler)
 
+	// Add SonarQube webhook endpoint
+	if s.webhookReceiver != nil {
+		mux.Handle("/webhook", webhookHandler(s.webhookSecret, s.webhookReceiver))
+	}
+
 	// Add root endpoint
 	mux.HandleFunc("/", rootHandler)
 
-	return &Server{
-		httpServer: &http.Server{
-			Addr:         address,
-			Handler:      mux,
-			ReadTimeout:  15 * time.Second,
-			WriteTimeout: 15 * time.Second,
-			IdleTimeout:  60 * time.Second,
-		},
-		registry: registry,
+	s.httpServer = &http.Server{
+		Addr:         address,
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
 	}
+	s.registry = registry
+
+	return s
+}
+
+// NewRegistry creates the Prometheus registry of the metrics exposed by the exporter
+func NewRegistry(collector prometheus.Collector) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	return registry
+}
+
+// Registry returns the Prometheus registry of the metrics exposed by the server
+func (s *Server) Registry() *prometheus.Registry {
+	return s.registry
 }
 
 // Start starts the HTTP server
@@ -35,6 +35,21 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewRegistry(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := metrics.NewCollector(client)
+
+	srv := New("localhost:9090", collector)
+	if srv.Registry() != srv.registry {
+		t.Error("Expected Registry to return the registry of the server")
+	}
+
+	// The collector can only be registered once per registry
+	if err := NewRegistry(collector).Register(collector); err == nil {
+		t.Error("Expected the collector to be registered in the new registry")
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// maxWebhookBodySize bounds the size of the webhook payloads, which are a few kilobytes
+const maxWebhookBodySize = 1 << 20
+
+// WebhookReceiver handles the analysis webhooks sent by SonarQube
+type WebhookReceiver interface {
+	HandleWebhook(payload sonarqube.WebhookPayload)
+}
+
+// webhookHandler handles the webhooks sent by SonarQube when an analysis completes. Webhooks
+// without a valid signature are rejected.
+func webhookHandler(secret string, receiver WebhookReceiver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if !sonarqube.VerifyWebhookSignature(body, secret, r.Header.Get(sonarqube.WebhookSignatureHeader)) {
+			log.Printf("Rejected SonarQube webhook from %s: invalid signature", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var payload sonarqube.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		if payload.Project.Key == "" {
+			http.Error(w, "invalid payload: missing project key", http.StatusBadRequest)
+			return
+		}
+
+		receiver.HandleWebhook(payload)
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "OK")
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/metrics"
+	"github.com/axopen/sonarqube-prometheus-exporter/internal/sonarqube"
+)
+
+// recordingReceiver records the webhooks it receives
+type recordingReceiver struct {
+	payloads []sonarqube.WebhookPayload
+}
+
+func (r *recordingReceiver) HandleWebhook(payload sonarqube.WebhookPayload) {
+	r.payloads = append(r.payloads, payload)
+}
+
+// sign returns the signature of a webhook body
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	body := `{"status":"SUCCESS","analysedAt":"2024-01-16T09:00:00+0000","project":{"key":"project1","name":"Project 1"}}`
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		sign     bool
+		expected int
+	}{
+		{name: "valid webhook", method: "POST", body: body, sign: true, expected: http.StatusOK},
+		{name: "missing signature", method: "POST", body: body, sign: false, expected: http.StatusUnauthorized},
+		{name: "invalid payload", method: "POST", body: "not json", sign: true, expected: http.StatusBadRequest},
+		{name: "missing project", method: "POST", body: `{"status":"SUCCESS"}`, sign: true, expected: http.StatusBadRequest},
+		{name: "wrong method", method: "GET", body: "", sign: false, expected: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &recordingReceiver{}
+			handler := webhookHandler("webhook-secret", receiver)
+
+			req := httptest.NewRequest(tt.method, "/webhook", strings.NewReader(tt.body))
+			if tt.sign {
+				req.Header.Set(sonarqube.WebhookSignatureHeader, sign(tt.body, "webhook-secret"))
+			}
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status code %d, got: %d", tt.expected, w.Code)
+			}
+
+			received := len(receiver.payloads) == 1
+			if received != (tt.expected == http.StatusOK) {
+				t.Errorf("Expected webhook to be received: %v, got: %d payloads", tt.expected == http.StatusOK, len(receiver.payloads))
+			}
+			if received && receiver.payloads[0].Project.Key != "project1" {
+				t.Errorf("Expected project 'project1', got: '%s'", receiver.payloads[0].Project.Key)
+			}
+		})
+	}
+}
+
+func TestNew_WithWebhook(t *testing.T) {
+	client := sonarqube.NewClient("https://sonar.example.com", "test-token")
+	collector := metrics.NewCollector(client)
+
+	// Without the option, /webhook falls through to the root page
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{name: "disabled", opts: nil, expected: http.StatusOK},
+		{name: "enabled", opts: []Option{WithWebhook("webhook-secret", &recordingReceiver{})}, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New("localhost:9090", collector, tt.opts...)
+
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader("{}"))
+			w := httptest.NewRecorder()
+			srv.httpServer.Handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status code %d, got: %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package sonarqube
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AuthMode is the way the Client authenticates to SonarQube
+type AuthMode string
+
+// Supported authentication modes
+const (
+	// AuthBearer sends the token as a bearer token, supported from SonarQube 10
+	AuthBearer AuthMode = "bearer"
+	// AuthBasicToken sends the token as the basic auth username with an empty password,
+	// supported by all SonarQube versions
+	AuthBasicToken AuthMode = "basic-token"
+	// AuthBasic sends a username and a password as basic auth
+	AuthBasic AuthMode = "basic"
+	// AuthHeader sends the token in a custom header, for auth proxies in front of SonarQube
+	AuthHeader AuthMode = "header"
+	// AuthAuto probes the bearer and basic-token modes at startup and keeps the first one that works
+	AuthAuto AuthMode = "auto"
+)
+
+// autoAuthModes are the modes probed, in order, by the auto mode
+var autoAuthModes = []AuthMode{AuthBearer, AuthBasicToken}
+
+// ParseAuthMode parses an authentication mode
+func ParseAuthMode(value string) (AuthMode, error) {
+	mode := AuthMode(strings.ToLower(strings.TrimSpace(value)))
+	switch mode {
+	case AuthBearer, AuthBasicToken, AuthBasic, AuthHeader, AuthAuto:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication mode %q (expected bearer, basic-token, basic, header or auto)", value)
+	}
+}
+
+// WithAuthMode sets the authentication mode. The auto mode behaves like the bearer one until
+// ProbeAuth selects the mode that works.
+func WithAuthMode(mode AuthMode) ClientOption {
+	return func(c *Client) {
+		c.authMode = mode
+	}
+}
+
+// WithBasicAuth sets the username and password used by the basic authentication mode
+func WithBasicAuth(username, password string) ClientOption {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithAuthHeader sets the header carrying the token in the header authentication mode
+func WithAuthHeader(name string) ClientOption {
+	return func(c *Client) {
+		c.authHeader = name
+	}
+}
+
+// authenticate sets the credentials of the request for the authentication mode
+func (c *Client) authenticate(req *http.Request, mode AuthMode, token string) {
+	switch mode {
+	case AuthBasicToken:
+		req.SetBasicAuth(token, "")
+	case AuthBasic:
+		req.SetBasicAuth(c.username, c.password)
+	case AuthHeader:
+		req.Header.Set(c.authHeader, token)
+	default:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+}
+
+// ProbeAuth checks that the configured authentication mode is accepted by SonarQube and returns it.
+// In auto mode, it tries the candidate modes in order and switches the client to the first one
+// that works.
+func (c *Client) ProbeAuth() (AuthMode, error) {
+	candidates := []AuthMode{c.currentAuthMode()}
+	if candidates[0] == AuthAuto {
+		candidates = autoAuthModes
+	}
+
+	var errs []error
+	for _, mode := range candidates {
+		var authResp AuthenticationResponse
+		err := c.request("/api/authentication/validate", nil, mode, c.currentToken(), &authResp)
+		if err == nil && authResp.Valid {
+			c.mu.Lock()
+			c.authMode = mode
+			c.mu.Unlock()
+			return mode, nil
+		}
+
+		if err == nil {
+			err = errors.New("credentials rejected")
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", mode, err))
+	}
+
+	return "", fmt.Errorf("authentication to SonarQube failed: %w", errors.Join(errs...))
+}
@@ -0,0 +1,93 @@
+package sonarqube
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAuthMode(t *testing.T) {
+	for _, value := range []string{"bearer", "basic-token", "basic", "header", "auto", " Bearer "} {
+		if _, err := ParseAuthMode(value); err != nil {
+			t.Errorf("Expected %q to be valid, got: %v", value, err)
+		}
+	}
+
+	if _, err := ParseAuthMode("kerberos"); err == nil {
+		t.Error("Expected error for an unsupported mode, got nil")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	client := NewClient("https://sonar.example.com", "test-token",
+		WithBasicAuth("exporter", "secret"),
+		WithAuthHeader("X-Auth-Token"),
+	)
+
+	tests := []struct {
+		mode     AuthMode
+		header   string
+		expected string
+	}{
+		{mode: AuthBearer, header: "Authorization", expected: "Bearer test-token"},
+		{mode: AuthBasicToken, header: "Authorization", expected: "Basic dGVzdC10b2tlbjo="},
+		{mode: AuthBasic, header: "Authorization", expected: "Basic ZXhwb3J0ZXI6c2VjcmV0"},
+		{mode: AuthHeader, header: "X-Auth-Token", expected: "test-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/metrics/search", nil)
+			client.authenticate(req, tt.mode, "test-token")
+
+			if value := req.Header.Get(tt.header); value != tt.expected {
+				t.Errorf("Expected %s header '%s', got: '%s'", tt.header, tt.expected, value)
+			}
+		})
+	}
+}
+
+// newBasicOnlyServer creates a mock SonarQube server that only accepts the token as basic auth username
+func newBasicOnlyServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _, ok := r.BasicAuth()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthenticationResponse{Valid: ok && username == "test-token"})
+	}))
+}
+
+func TestProbeAuth_Auto(t *testing.T) {
+	server := newBasicOnlyServer()
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", WithAuthMode(AuthAuto))
+	mode, err := client.ProbeAuth()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if mode != AuthBasicToken {
+		t.Errorf("Expected mode '%s', got: '%s'", AuthBasicToken, mode)
+	}
+
+	if client.currentAuthMode() != AuthBasicToken {
+		t.Errorf("Expected client to switch to '%s', got: '%s'", AuthBasicToken, client.currentAuthMode())
+	}
+}
+
+func TestProbeAuth_Rejected(t *testing.T) {
+	server := newBasicOnlyServer()
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	if _, err := client.ProbeAuth(); err == nil {
+		t.Fatal("Expected error when the bearer mode is rejected, got nil")
+	}
+
+	if client.currentAuthMode() != AuthBearer {
+		t.Errorf("Expected client to keep the '%s' mode, got: '%s'", AuthBearer, client.currentAuthMode())
+	}
+}
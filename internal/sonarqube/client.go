@@ -2,9 +2,15 @@ package sonarqube
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,134 +18,383 @@ import (
 type Client struct {
 	baseURL    string
 	token      string
+	adminToken string
 	httpClient *http.Client
+	transport  *http.Transport
+	dialer     *net.Dialer
+	mu         sync.RWMutex
+
+	// Authentication
+	authMode   AuthMode
+	username   string
+	password   string
+	authHeader string
+}
+
+// ClientOption configures optional behaviour of the Client
+type ClientOption func(*Client)
+
+// WithAdminToken sets the token used for the endpoints that require administrator permissions,
+// such as the license ones. The regular credentials are used when it is empty.
+func WithAdminToken(token string) ClientOption {
+	return func(c *Client) {
+		c.adminToken = token
+	}
 }
 
 // NewClient creates a new SonarQube client
-func NewClient(baseURL, token string) *Client {
-	return &Client{
-		baseURL: baseURL,
-		token:   token,
+func NewClient(baseURL, token string, opts ...ClientOption) *Client {
+	// Same dialer settings as the default transport, kept to let options tune them
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	c := &Client{
+		baseURL:   baseURL,
+		token:     token,
+		authMode:  AuthBearer,
+		transport: transport,
+		dialer:    dialer,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
-// GetMetrics retrieves all available metrics from SonarQube
+// GetMetrics retrieves all available metrics from SonarQube, including the custom metrics of plugins
 func (c *Client) GetMetrics() ([]Metric, error) {
-	url := fmt.Sprintf("%s/api/metrics/search?ps=500", c.baseURL)
+	var allMetrics []Metric
+
+	err := paginate("/api/metrics/search", maxMetricPages, func(pageIndex int) (int, int, error) {
+		params := url.Values{}
+		params.Set("ps", strconv.Itoa(metricsPageSize))
+		params.Set("p", strconv.Itoa(pageIndex))
 
-	req, err := http.NewRequest("GET", url, nil)
+		var metricsResp MetricsResponse
+		if err := c.get("/api/metrics/search", params, &metricsResp); err != nil {
+			return 0, 0, err
+		}
+
+		allMetrics = append(allMetrics, metricsResp.Metrics...)
+		return len(metricsResp.Metrics), metricsResp.total(), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	return allMetrics, nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch metrics: %w", err)
+// GetProjectMeasures retrieves measures for a specific project
+func (c *Client) GetProjectMeasures(projectKey string, metricKeys []string) ([]Measure, error) {
+	if len(metricKeys) == 0 {
+		return []Measure{}, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	params := url.Values{}
+	params.Set("component", projectKey)
+	params.Set("metricKeys", strings.Join(metricKeys, ","))
+
+	var measuresResp MeasuresResponse
+	if err := c.get("/api/measures/component", params, &measuresResp); err != nil {
+		return nil, err
 	}
 
-	var metricsResp MetricsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&metricsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode metrics response: %w", err)
+	return measuresResp.Component.Measures, nil
+}
+
+// GetLatestAnalysis retrieves the latest analysis of a project, or nil if it has never been analyzed
+func (c *Client) GetLatestAnalysis(projectKey string) (*Analysis, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+	params.Set("ps", "1")
+
+	var analysesResp ProjectAnalysesResponse
+	if err := c.get("/api/project_analyses/search", params, &analysesResp); err != nil {
+		return nil, err
+	}
+
+	if len(analysesResp.Analyses) == 0 {
+		return nil, nil
 	}
 
-	return metricsResp.Metrics, nil
+	return &analysesResp.Analyses[0], nil
 }
 
-// GetProjects retrieves all projects from SonarQube
-func (c *Client) GetProjects() ([]Component, error) {
-	var allComponents []Component
-	pageIndex := 1
-	pageSize := 500
+// CountAnalysisEvents counts the analyses of a project that have an event of the given category
+func (c *Client) CountAnalysisEvents(projectKey, category string) (int, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+	params.Set("category", category)
+	params.Set("ps", "1")
 
-	for {
-		url := fmt.Sprintf("%s/api/components/search_projects?ps=%d&p=%d", c.baseURL, pageSize, pageIndex)
+	var analysesResp ProjectAnalysesResponse
+	if err := c.get("/api/project_analyses/search", params, &analysesResp); err != nil {
+		return 0, err
+	}
 
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
+	return analysesResp.Paging.Total, nil
+}
 
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+const (
+	// metricsPageSize is the maximum page size of /api/metrics/search
+	metricsPageSize = 500
+	// maxMetricPages is the page limit of /api/metrics/search
+	maxMetricPages = 100
+	// hotspotsPageSize is the maximum page size of /api/hotspots/search
+	hotspotsPageSize = 500
+)
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch projects: %w", err)
-		}
-		defer resp.Body.Close()
+// ErrHotspotListTruncated is returned with the hotspots listed so far when a project has more
+// hotspots than the search index pages through
+var ErrHotspotListTruncated = errors.New("hotspot list truncated")
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-		}
+// CountProjectHotspots counts the security hotspots of a project with the given status and, if
+// not empty, resolution, without listing them
+func (c *Client) CountProjectHotspots(projectKey, status, resolution string) (int, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+	params.Set("status", status)
+	if resolution != "" {
+		params.Set("resolution", resolution)
+	}
+	params.Set("ps", "1")
 
-		var componentsResp ComponentsResponse
-		if err := json.NewDecoder(resp.Body).Decode(&componentsResp); err != nil {
-			return nil, fmt.Errorf("failed to decode components response: %w", err)
-		}
+	var hotspotsResp HotspotsResponse
+	if err := c.get("/api/hotspots/search", params, &hotspotsResp); err != nil {
+		return 0, err
+	}
 
-		allComponents = append(allComponents, componentsResp.Components...)
+	return hotspotsResp.Paging.Total, nil
+}
+
+// GetProjectHotspots retrieves the security hotspots of a project. Like project search, hotspot
+// search only pages through the first 10,000 results: beyond them, the hotspots listed so far are
+// returned with ErrHotspotListTruncated.
+func (c *Client) GetProjectHotspots(projectKey string) ([]Hotspot, error) {
+	var allHotspots []Hotspot
+	total := 0
 
-		// Check if we've retrieved all projects
-		if len(allComponents) >= componentsResp.Paging.Total {
-			break
+	err := paginate("/api/hotspots/search", searchWindow/hotspotsPageSize, func(pageIndex int) (int, int, error) {
+		params := url.Values{}
+		params.Set("project", projectKey)
+		params.Set("ps", strconv.Itoa(hotspotsPageSize))
+		params.Set("p", strconv.Itoa(pageIndex))
+
+		var hotspotsResp HotspotsResponse
+		if err := c.get("/api/hotspots/search", params, &hotspotsResp); err != nil {
+			return 0, 0, err
 		}
+		total = hotspotsResp.Paging.Total
 
-		pageIndex++
+		allHotspots = append(allHotspots, hotspotsResp.Hotspots...)
+		return len(hotspotsResp.Hotspots), total, nil
+	})
+	if errors.Is(err, errPageLimit) {
+		return allHotspots, fmt.Errorf("%w: %d of %d hotspots listed", ErrHotspotListTruncated, len(allHotspots), total)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return allComponents, nil
+	return allHotspots, nil
 }
 
-// GetProjectMeasures retrieves measures for a specific project
-func (c *Client) GetProjectMeasures(projectKey string, metricKeys []string) ([]Measure, error) {
-	if len(metricKeys) == 0 {
-		return []Measure{}, nil
+// GetSecurityReport retrieves the security report of a project for a security standard
+func (c *Client) GetSecurityReport(projectKey, standard string) (*SecurityReport, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+	params.Set("standard", standard)
+
+	var report SecurityReport
+	if err := c.get("/api/security_reports/show", params, &report); err != nil {
+		return nil, err
 	}
 
-	// Build metric keys parameter
-	metricsParam := ""
-	for i, key := range metricKeys {
-		if i > 0 {
-			metricsParam += ","
+	return &report, nil
+}
+
+// GetQualityProfiles retrieves all quality profiles
+func (c *Client) GetQualityProfiles() ([]QualityProfile, error) {
+	var profilesResp QualityProfilesResponse
+	if err := c.get("/api/qualityprofiles/search", nil, &profilesResp); err != nil {
+		return nil, err
+	}
+
+	return profilesResp.Profiles, nil
+}
+
+// GetProjectQualityProfiles retrieves the quality profiles used by a project, one per language
+func (c *Client) GetProjectQualityProfiles(projectKey string) ([]QualityProfile, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+
+	var profilesResp QualityProfilesResponse
+	if err := c.get("/api/qualityprofiles/search", params, &profilesResp); err != nil {
+		return nil, err
+	}
+
+	return profilesResp.Profiles, nil
+}
+
+// GetQualityGateStatus retrieves the quality gate status of the main branch of a project, with the
+// status of each of its conditions
+func (c *Client) GetQualityGateStatus(projectKey string) (*ProjectStatus, error) {
+	params := url.Values{}
+	params.Set("projectKey", projectKey)
+
+	var statusResp ProjectStatusResponse
+	if err := c.get("/api/qualitygates/project_status", params, &statusResp); err != nil {
+		return nil, err
+	}
+
+	return &statusResp.ProjectStatus, nil
+}
+
+// GetMainBranch retrieves the name of the main branch of a project
+func (c *Client) GetMainBranch(projectKey string) (string, error) {
+	params := url.Values{}
+	params.Set("project", projectKey)
+
+	var branchesResp ProjectBranchesResponse
+	if err := c.get("/api/project_branches/list", params, &branchesResp); err != nil {
+		return "", err
+	}
+
+	for _, branch := range branchesResp.Branches {
+		if branch.IsMain {
+			return branch.Name, nil
 		}
-		metricsParam += key
 	}
 
-	url := fmt.Sprintf("%s/api/measures/component?component=%s&metricKeys=%s", c.baseURL, projectKey, metricsParam)
+	return "", nil
+}
+
+// DashboardURL returns the URL of the dashboard of a project in the SonarQube web interface
+func (c *Client) DashboardURL(projectKey string) string {
+	return c.baseURL + "/dashboard?id=" + url.QueryEscape(projectKey)
+}
+
+// ValidateAuthentication checks whether the token is valid
+func (c *Client) ValidateAuthentication() (bool, error) {
+	var authResp AuthenticationResponse
+	if err := c.get("/api/authentication/validate", nil, &authResp); err != nil {
+		return false, err
+	}
+
+	return authResp.Valid, nil
+}
+
+// GetUserTokens retrieves the tokens of the user the token belongs to
+func (c *Client) GetUserTokens() ([]UserToken, error) {
+	var tokensResp UserTokensResponse
+	if err := c.get("/api/user_tokens/search", nil, &tokensResp); err != nil {
+		return nil, err
+	}
+
+	return tokensResp.UserTokens, nil
+}
+
+// GetLicense retrieves the license of a commercial edition. It requires administrator permissions
+// and fails with a 404 status code on the Community Edition.
+func (c *Client) GetLicense() (*License, error) {
+	var license License
+	if err := c.getAdmin("/api/editions/show_license", nil, &license); err != nil {
+		return nil, err
+	}
+
+	return &license, nil
+}
+
+// GetLicenseUsage retrieves the lines of code of each project counted against the license.
+// It requires administrator permissions.
+func (c *Client) GetLicenseUsage() ([]ProjectLicenseUsage, error) {
+	var usageResp LicenseUsageResponse
+	if err := c.getAdmin("/api/projects/license_usage", nil, &usageResp); err != nil {
+		return nil, err
+	}
+
+	return usageResp.Projects, nil
+}
+
+// SetToken replaces the token used to authenticate, e.g. after a rotation
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// currentToken returns the token used to authenticate
+func (c *Client) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// currentAuthMode returns the authentication mode in use
+func (c *Client) currentAuthMode() AuthMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authMode
+}
+
+// get performs a GET request on a SonarQube Web API endpoint and decodes the JSON response into target
+func (c *Client) get(endpoint string, params url.Values, target interface{}) error {
+	return c.request(endpoint, params, c.currentAuthMode(), c.currentToken(), target)
+}
+
+// getAdmin performs a GET request like get, authenticated with the admin token if any. As the basic
+// mode authenticates with a username and a password, the admin token is then sent as a basic-token.
+func (c *Client) getAdmin(endpoint string, params url.Values, target interface{}) error {
+	if c.adminToken == "" {
+		return c.get(endpoint, params, target)
+	}
+
+	mode := c.currentAuthMode()
+	if mode == AuthBasic {
+		mode = AuthBasicToken
+	}
+	return c.request(endpoint, params, mode, c.adminToken, target)
+}
+
+// request performs a GET request authenticated with the mode and token, and decodes the JSON response into target
+func (c *Client) request(endpoint string, params url.Values, mode AuthMode, token string, target interface{}) error {
+	reqURL := c.baseURL + endpoint
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest("GET", reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	c.authenticate(req, mode, token)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch project measures: %w", err)
+		return &APIError{Endpoint: endpoint, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return &APIError{Endpoint: endpoint, StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	var measuresResp MeasuresResponse
-	if err := json.NewDecoder(resp.Body).Decode(&measuresResp); err != nil {
-		return nil, fmt.Errorf("failed to decode measures response: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return &APIError{Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
 	}
 
-	return measuresResp.Component.Measures, nil
+	return nil
 }
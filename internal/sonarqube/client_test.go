@@ -2,9 +2,11 @@ package sonarqube
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestGetMetrics_Success(t *testing.T) {
@@ -58,6 +60,38 @@ func TestGetMetrics_Success(t *testing.T) {
 	}
 }
 
+func TestGetMetrics_Pagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("p")
+
+		// Custom plugin metrics come after the built-in ones, on the second page
+		response := MetricsResponse{Paging: &Paging{Total: 3}}
+		switch page {
+		case "1":
+			response.Metrics = []Metric{{Key: "bugs"}, {Key: "coverage"}}
+		case "2":
+			response.Metrics = []Metric{{Key: "custom_plugin_metric"}}
+		default:
+			t.Errorf("Unexpected page %s", page)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	metrics, err := client.GetMetrics()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(metrics) != 3 || metrics[2].Key != "custom_plugin_metric" {
+		t.Errorf("Expected 3 metrics ending with 'custom_plugin_metric', got: %v", metrics)
+	}
+}
+
 func TestGetMetrics_Unauthorized(t *testing.T) {
 	// Create mock server that returns 401
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,6 +106,10 @@ func TestGetMetrics_Unauthorized(t *testing.T) {
 	if err == nil {
 		t.Fatal("Expected error, got nil")
 	}
+
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got: %v", err)
+	}
 }
 
 func TestGetProjects_Success(t *testing.T) {
@@ -205,3 +243,502 @@ func TestNewClient(t *testing.T) {
 		t.Error("Expected httpClient to be initialized")
 	}
 }
+
+func TestParseDateTime(t *testing.T) {
+	parsed, err := ParseDateTime("2024-01-15T10:30:00+0100")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+	if !parsed.Equal(expected) {
+		t.Errorf("Expected %v, got: %v", expected, parsed)
+	}
+
+	if _, err := ParseDateTime("15/01/2024"); err == nil {
+		t.Error("Expected error for invalid date, got nil")
+	}
+}
+
+func TestGetLatestAnalysis_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/project_analyses/search" {
+			t.Errorf("Expected path '/api/project_analyses/search', got: %s", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("project") != "project1" {
+			t.Errorf("Expected project 'project1', got: %s", r.URL.Query().Get("project"))
+		}
+
+		response := ProjectAnalysesResponse{
+			Paging: Paging{PageIndex: 1, PageSize: 1, Total: 12},
+			Analyses: []Analysis{
+				{
+					Key:            "AU-1",
+					Date:           "2024-01-15T10:30:00+0100",
+					ProjectVersion: "1.4.0",
+					Revision:       "abc123",
+					DetectedCI:     "Github Actions",
+					Events: []AnalysisEvent{
+						{Key: "E1", Category: EventCategoryVersion, Name: "1.4.0"},
+					},
+				},
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	analysis, err := client.GetLatestAnalysis("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if analysis == nil {
+		t.Fatal("Expected an analysis, got nil")
+	}
+
+	if analysis.ProjectVersion != "1.4.0" || analysis.Revision != "abc123" {
+		t.Errorf("Expected version '1.4.0' and revision 'abc123', got: %s and %s", analysis.ProjectVersion, analysis.Revision)
+	}
+}
+
+func TestGetLatestAnalysis_NoAnalysis(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ProjectAnalysesResponse{})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	analysis, err := client.GetLatestAnalysis("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if analysis != nil {
+		t.Errorf("Expected no analysis, got: %+v", analysis)
+	}
+}
+
+func TestCountAnalysisEvents_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("category") != EventCategoryQualityGate {
+			t.Errorf("Expected category '%s', got: %s", EventCategoryQualityGate, r.URL.Query().Get("category"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ProjectAnalysesResponse{
+			Paging: Paging{PageIndex: 1, PageSize: 1, Total: 7},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	count, err := client.CountAnalysisEvents("project1", EventCategoryQualityGate)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if count != 7 {
+		t.Errorf("Expected 7 events, got: %d", count)
+	}
+}
+
+func TestGetProjectHotspots_Pagination(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/hotspots/search" {
+			t.Errorf("Expected path '/api/hotspots/search', got: %s", r.URL.Path)
+		}
+		requests++
+
+		response := HotspotsResponse{
+			Paging: Paging{PageIndex: requests, PageSize: 500, Total: 3},
+		}
+		switch r.URL.Query().Get("p") {
+		case "1":
+			response.Hotspots = []Hotspot{
+				{Key: "H1", Status: "TO_REVIEW", VulnerabilityProbability: "HIGH"},
+				{Key: "H2", Status: "REVIEWED", Resolution: "SAFE", VulnerabilityProbability: "LOW"},
+			}
+		case "2":
+			response.Hotspots = []Hotspot{
+				{Key: "H3", Status: "REVIEWED", Resolution: "FIXED", VulnerabilityProbability: "MEDIUM"},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	hotspots, err := client.GetProjectHotspots("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(hotspots) != 3 {
+		t.Errorf("Expected 3 hotspots, got: %d", len(hotspots))
+	}
+
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got: %d", requests)
+	}
+}
+
+func TestGetProjectHotspots_EmptyPage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		// Reports more hotspots than it actually returns
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HotspotsResponse{
+			Paging: Paging{PageIndex: requests, PageSize: 500, Total: 10},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	hotspots, err := client.GetProjectHotspots("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(hotspots) != 0 || requests != 1 {
+		t.Errorf("Expected to stop after an empty page, got %d hotspots in %d requests", len(hotspots), requests)
+	}
+}
+
+func TestGetProjectHotspots_SearchWindow(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		response := HotspotsResponse{
+			Paging: Paging{PageIndex: requests, PageSize: hotspotsPageSize, Total: searchWindow + 1},
+		}
+		for i := 0; i < hotspotsPageSize; i++ {
+			response.Hotspots = append(response.Hotspots, Hotspot{Status: "TO_REVIEW", VulnerabilityProbability: "LOW"})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	hotspots, err := client.GetProjectHotspots("project1")
+
+	if !errors.Is(err, ErrHotspotListTruncated) {
+		t.Fatalf("Expected ErrHotspotListTruncated, got: %v", err)
+	}
+
+	if len(hotspots) != searchWindow {
+		t.Errorf("Expected the %d hotspots of the search window, got: %d", searchWindow, len(hotspots))
+	}
+
+	if requests != searchWindow/hotspotsPageSize {
+		t.Errorf("Expected %d requests, got: %d", searchWindow/hotspotsPageSize, requests)
+	}
+
+	if reason := ErrorReason(err); reason != "truncated" {
+		t.Errorf("Expected reason 'truncated', got: '%s'", reason)
+	}
+}
+
+func TestCountProjectHotspots_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("status") != "REVIEWED" || query.Get("resolution") != "SAFE" || query.Get("ps") != "1" {
+			t.Errorf("Expected a single-result REVIEWED/SAFE search, got: %s", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HotspotsResponse{
+			Paging:   Paging{PageIndex: 1, PageSize: 1, Total: 42},
+			Hotspots: []Hotspot{{Key: "H1", Status: "REVIEWED", Resolution: "SAFE"}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	count, err := client.CountProjectHotspots("project1", "REVIEWED", "SAFE")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if count != 42 {
+		t.Errorf("Expected 42 hotspots, got: %d", count)
+	}
+}
+
+func TestGetSecurityReport_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/security_reports/show" {
+			t.Errorf("Expected path '/api/security_reports/show', got: %s", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("standard") != SecurityStandardOWASPTop102021 {
+			t.Errorf("Expected standard '%s', got: %s", SecurityStandardOWASPTop102021, r.URL.Query().Get("standard"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"categories":[
+			{"category":"a1","vulnerabilities":2,"vulnerabilityRating":3,"toReviewSecurityHotspots":1,"reviewedSecurityHotspots":4,"securityReviewRating":2},
+			{"category":"a3","vulnerabilities":0,"toReviewSecurityHotspots":0,"reviewedSecurityHotspots":0}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	report, err := client.GetSecurityReport("project1", SecurityStandardOWASPTop102021)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(report.Categories) != 2 {
+		t.Fatalf("Expected 2 categories, got: %d", len(report.Categories))
+	}
+
+	first := report.Categories[0]
+	if first.Category != "a1" || first.Vulnerabilities != 2 || first.ReviewedSecurityHotspots != 4 {
+		t.Errorf("Unexpected category decoded: %+v", first)
+	}
+}
+
+func TestGetQualityProfiles_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/qualityprofiles/search" {
+			t.Errorf("Expected path '/api/qualityprofiles/search', got: %s", r.URL.Path)
+		}
+
+		profiles := []QualityProfile{
+			{Key: "java-sonar-way", Name: "Sonar way", Language: "java", IsDefault: true, IsBuiltIn: true, ActiveRuleCount: 500},
+			{Key: "java-company", Name: "Company Way", Language: "java", ParentKey: "java-sonar-way", IsInherited: true},
+		}
+		if r.URL.Query().Get("project") == "project1" {
+			profiles = profiles[1:]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(QualityProfilesResponse{Profiles: profiles})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	profiles, err := client.GetQualityProfiles()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].ActiveRuleCount != 500 {
+		t.Errorf("Expected 2 profiles with 500 active rules for the first one, got: %+v", profiles)
+	}
+
+	projectProfiles, err := client.GetProjectQualityProfiles("project1")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(projectProfiles) != 1 || projectProfiles[0].Key != "java-company" {
+		t.Errorf("Expected the 'java-company' profile, got: %+v", projectProfiles)
+	}
+}
+
+func TestGetQualityGateStatus_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/qualitygates/project_status" {
+			t.Errorf("Expected path '/api/qualitygates/project_status', got: %s", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("projectKey") != "project1" {
+			t.Errorf("Expected projectKey 'project1', got: %s", r.URL.Query().Get("projectKey"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"projectStatus":{"status":"ERROR","conditions":[
+			{"status":"ERROR","metricKey":"new_coverage","comparator":"LT","errorThreshold":"80","actualValue":"42.5"},
+			{"status":"OK","metricKey":"new_duplicated_lines_density","comparator":"GT","errorThreshold":"3","actualValue":"0.0"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	status, err := client.GetQualityGateStatus("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if status.Status != "ERROR" || len(status.Conditions) != 2 {
+		t.Fatalf("Expected an ERROR status with 2 conditions, got: %+v", status)
+	}
+
+	first := status.Conditions[0]
+	if first.MetricKey != "new_coverage" || first.ActualValue != "42.5" || first.ErrorThreshold != "80" {
+		t.Errorf("Unexpected condition decoded: %+v", first)
+	}
+}
+
+func TestGetMainBranch_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/project_branches/list" {
+			t.Errorf("Expected path '/api/project_branches/list', got: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ProjectBranchesResponse{
+			Branches: []ProjectBranch{
+				{Name: "feature", Type: "BRANCH"},
+				{Name: "develop", Type: "BRANCH", IsMain: true},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	branch, err := client.GetMainBranch("project1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if branch != "develop" {
+		t.Errorf("Expected main branch 'develop', got: '%s'", branch)
+	}
+}
+
+func TestDashboardURL(t *testing.T) {
+	client := NewClient("https://sonar.example.com", "test-token")
+
+	if url := client.DashboardURL("group:project 1"); url != "https://sonar.example.com/dashboard?id=group%3Aproject+1" {
+		t.Errorf("Unexpected dashboard URL: %s", url)
+	}
+}
+
+func TestGetLicense_AdminToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/editions/show_license" {
+			t.Errorf("Expected path '/api/editions/show_license', got: %s", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			t.Errorf("Expected Authorization header 'Bearer admin-token', got: %s", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(License{Edition: "Enterprise", MaxLoc: 1000000, Loc: 250000, ExpiresAt: "2030-01-31"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", WithAdminToken("admin-token"))
+	license, err := client.GetLicense()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if license.MaxLoc != 1000000 || license.Loc != 250000 {
+		t.Errorf("Unexpected license decoded: %+v", license)
+	}
+}
+
+func TestGetLicense_AdminTokenBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The admin token replaces the username and password, as a basic-token
+		if user, password, _ := r.BasicAuth(); user != "admin-token" || password != "" {
+			t.Errorf("Expected the admin token as basic auth username, got: %s:%s", user, password)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(License{Edition: "Enterprise", MaxLoc: 1000000, Loc: 250000})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "",
+		WithAuthMode(AuthBasic),
+		WithBasicAuth("exporter", "secret"),
+		WithAdminToken("admin-token"),
+	)
+	if _, err := client.GetLicense(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+}
+
+func TestGetLicense_Forbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected the regular token without an admin token, got: %s", r.Header.Get("Authorization"))
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Insufficient privileges"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	_, err := client.GetLicense()
+
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden, got: %v", err)
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		t.Error("Expected a forbidden error not to be a not found error")
+	}
+}
+
+func TestValidateAuthentication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/authentication/validate" {
+			t.Errorf("Expected path '/api/authentication/validate', got: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthenticationResponse{Valid: r.Header.Get("Authorization") == "Bearer test-token"})
+	}))
+	defer server.Close()
+
+	valid, err := NewClient(server.URL, "test-token").ValidateAuthentication()
+	if err != nil || !valid {
+		t.Errorf("Expected a valid token, got: %v, %v", valid, err)
+	}
+
+	valid, err = NewClient(server.URL, "revoked-token").ValidateAuthentication()
+	if err != nil || valid {
+		t.Errorf("Expected an invalid token, got: %v, %v", valid, err)
+	}
+}
+
+func TestGetUserTokens_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user_tokens/search" {
+			t.Errorf("Expected path '/api/user_tokens/search', got: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"login":"exporter","userTokens":[{"name":"prometheus","createdAt":"2024-01-01T00:00:00+0000","expirationDate":"2024-04-01T00:00:00+0000"}]}`))
+	}))
+	defer server.Close()
+
+	tokens, err := NewClient(server.URL, "test-token").GetUserTokens()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(tokens) != 1 || tokens[0].Name != "prometheus" || tokens[0].ExpirationDate == "" {
+		t.Errorf("Unexpected tokens decoded: %+v", tokens)
+	}
+}
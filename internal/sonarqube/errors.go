@@ -0,0 +1,105 @@
+package sonarqube
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the Client, classified from the status code of the response.
+// Use errors.Is to check them.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// APIError is returned when a request to the SonarQube Web API fails. StatusCode is zero when no
+// response was received, and Err holds the transport or decoding error, if any.
+type APIError struct {
+	Endpoint   string
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	switch {
+	case e.StatusCode == 0:
+		return fmt.Sprintf("failed to fetch %s: %v", e.Endpoint, e.Err)
+	case e.Err != nil:
+		return fmt.Sprintf("failed to decode %s response: %v", e.Endpoint, e.Err)
+	default:
+		return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+	}
+}
+
+// Unwrap returns the underlying transport or decoding error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the status code of the error matches one of the classified errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// ErrorReason classifies an error returned by the Client into a short reason, suitable as a label value
+func ErrorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrServer):
+		return "server_error"
+	case errors.Is(err, ErrProjectListTruncated), errors.Is(err, ErrHotspotListTruncated):
+		return "truncated"
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return "other"
+	}
+
+	switch {
+	case apiErr.StatusCode == 0:
+		return "network"
+	case apiErr.Err != nil:
+		return "invalid_response"
+	default:
+		return "unexpected_status"
+	}
+}
+
+// ErrorEndpoint returns the endpoint of the request that failed, or "unknown"
+func ErrorEndpoint(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Endpoint
+	}
+	if errors.Is(err, ErrHotspotListTruncated) {
+		return "/api/hotspots/search"
+	}
+	return "unknown"
+}
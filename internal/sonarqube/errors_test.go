@@ -0,0 +1,74 @@
+package sonarqube
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   error
+		reason     string
+	}{
+		{statusCode: http.StatusUnauthorized, expected: ErrUnauthorized, reason: "unauthorized"},
+		{statusCode: http.StatusForbidden, expected: ErrForbidden, reason: "forbidden"},
+		{statusCode: http.StatusNotFound, expected: ErrNotFound, reason: "not_found"},
+		{statusCode: http.StatusTooManyRequests, expected: ErrRateLimited, reason: "rate_limited"},
+		{statusCode: http.StatusBadGateway, expected: ErrServer, reason: "server_error"},
+		{statusCode: http.StatusBadRequest, expected: nil, reason: "unexpected_status"},
+	}
+
+	sentinels := []error{ErrUnauthorized, ErrForbidden, ErrNotFound, ErrRateLimited, ErrServer}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.statusCode), func(t *testing.T) {
+			// Wrapped errors must still be classified
+			err := fmt.Errorf("wrapped: %w", &APIError{Endpoint: "/api/metrics/search", StatusCode: tt.statusCode})
+
+			for _, sentinel := range sentinels {
+				if errors.Is(err, sentinel) != (sentinel == tt.expected) {
+					t.Errorf("Unexpected errors.Is(err, %v) result", sentinel)
+				}
+			}
+
+			if reason := ErrorReason(err); reason != tt.reason {
+				t.Errorf("Expected reason '%s', got: '%s'", tt.reason, reason)
+			}
+
+			if endpoint := ErrorEndpoint(err); endpoint != "/api/metrics/search" {
+				t.Errorf("Expected endpoint '/api/metrics/search', got: '%s'", endpoint)
+			}
+		})
+	}
+}
+
+func TestErrorReason_RequestFailures(t *testing.T) {
+	// Nothing listens on this server once closed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+
+	client := NewClient(server.URL, "test-token")
+	_, err := client.GetMetrics()
+	if reason := ErrorReason(err); reason != "invalid_response" {
+		t.Errorf("Expected reason 'invalid_response', got: '%s' (%v)", reason, err)
+	}
+
+	server.Close()
+	_, err = client.GetMetrics()
+	if reason := ErrorReason(err); reason != "network" {
+		t.Errorf("Expected reason 'network', got: '%s' (%v)", reason, err)
+	}
+
+	if reason := ErrorReason(errors.New("boom")); reason != "other" {
+		t.Errorf("Expected reason 'other', got: '%s'", reason)
+	}
+
+	if endpoint := ErrorEndpoint(errors.New("boom")); endpoint != "unknown" {
+		t.Errorf("Expected endpoint 'unknown', got: '%s'", endpoint)
+	}
+}
@@ -0,0 +1,80 @@
+package sonarqube
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// historyPageSize is the maximum page size of /api/measures/search_history, in analyses
+	historyPageSize = 1000
+	// historyMetricsPerRequest bounds the metrics requested at once, to keep request URLs short
+	historyMetricsPerRequest = 15
+	// maxHistoryPages is the page limit of /api/measures/search_history
+	maxHistoryPages = 1000
+)
+
+// GetMeasuresHistory retrieves the values of metrics at each analysis of a project, between from
+// and to. A zero from or to leaves the period open on that side.
+func (c *Client) GetMeasuresHistory(projectKey string, metricKeys []string, from, to time.Time) ([]MeasureHistory, error) {
+	var allHistory []MeasureHistory
+
+	for start := 0; start < len(metricKeys); start += historyMetricsPerRequest {
+		end := min(start+historyMetricsPerRequest, len(metricKeys))
+
+		history, err := c.searchHistory(projectKey, metricKeys[start:end], from, to)
+		if err != nil {
+			return nil, err
+		}
+		allHistory = append(allHistory, history...)
+	}
+
+	return allHistory, nil
+}
+
+// searchHistory pages through /api/measures/search_history for a batch of metrics, merging the
+// values of each metric across pages
+func (c *Client) searchHistory(projectKey string, metricKeys []string, from, to time.Time) ([]MeasureHistory, error) {
+	var allHistory []MeasureHistory
+	index := make(map[string]int)
+
+	err := paginate("/api/measures/search_history", maxHistoryPages, func(pageIndex int) (int, int, error) {
+		params := url.Values{}
+		params.Set("component", projectKey)
+		params.Set("metrics", strings.Join(metricKeys, ","))
+		params.Set("ps", strconv.Itoa(historyPageSize))
+		params.Set("p", strconv.Itoa(pageIndex))
+		if !from.IsZero() {
+			params.Set("from", from.Format(DateTimeLayout))
+		}
+		if !to.IsZero() {
+			params.Set("to", to.Format(DateTimeLayout))
+		}
+
+		var historyResp MeasuresHistoryResponse
+		if err := c.get("/api/measures/search_history", params, &historyResp); err != nil {
+			return 0, 0, err
+		}
+
+		// Pages hold the same analyses for every metric, and the total counts analyses
+		analyses := 0
+		for _, measure := range historyResp.Measures {
+			analyses = max(analyses, len(measure.History))
+
+			if i, exists := index[measure.Metric]; exists {
+				allHistory[i].History = append(allHistory[i].History, measure.History...)
+				continue
+			}
+			index[measure.Metric] = len(allHistory)
+			allHistory = append(allHistory, measure)
+		}
+		return analyses, historyResp.Paging.Total, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allHistory, nil
+}
@@ -0,0 +1,88 @@
+package sonarqube
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetMeasuresHistory_Pagination(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/measures/search_history" {
+			t.Errorf("Expected path '/api/measures/search_history', got: %s", r.URL.Path)
+		}
+
+		query := r.URL.Query()
+		if query.Get("component") != "project1" {
+			t.Errorf("Expected component 'project1', got: %s", query.Get("component"))
+		}
+		if query.Get("from") != "2023-01-01T00:00:00+0000" {
+			t.Errorf("Expected from '2023-01-01T00:00:00+0000', got: %s", query.Get("from"))
+		}
+		if query.Has("to") {
+			t.Errorf("Expected no to parameter, got: %s", query.Get("to"))
+		}
+
+		mu.Lock()
+		requested = append(requested, query.Get("metrics")+"@"+query.Get("p"))
+		mu.Unlock()
+
+		// 1,500 analyses of the first metric batch span two pages
+		metrics := strings.Split(query.Get("metrics"), ",")
+		response := MeasuresHistoryResponse{Paging: Paging{Total: 1}}
+		analyses := 1
+		if metrics[0] == "metric0" {
+			page, _ := strconv.Atoi(query.Get("p"))
+			response.Paging.Total = 1500
+			analyses = min(response.Paging.Total-(page-1)*historyPageSize, historyPageSize)
+		}
+		for _, metric := range metrics {
+			measure := MeasureHistory{Metric: metric}
+			for range analyses {
+				measure.History = append(measure.History, HistoryValue{Date: "2023-06-01T10:00:00+0000", Value: query.Get("p")})
+			}
+			response.Measures = append(response.Measures, measure)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	metricKeys := make([]string, 0, 16)
+	for i := 0; i < 16; i++ {
+		metricKeys = append(metricKeys, fmt.Sprintf("metric%d", i))
+	}
+
+	client := NewClient(server.URL, "test-token")
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	history, err := client.GetMeasuresHistory("project1", metricKeys, from, time.Time{})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// 15 metrics over two pages, then the last metric on its own
+	if len(requested) != 3 {
+		t.Errorf("Expected 3 requests, got: %v", requested)
+	}
+
+	if len(history) != 16 {
+		t.Fatalf("Expected the history of 16 metrics, got: %d", len(history))
+	}
+	if history[0].Metric != "metric0" || len(history[0].History) != 1500 || history[0].History[historyPageSize].Value != "2" {
+		t.Errorf("Expected the values of both pages to be merged, got: %+v", history[0])
+	}
+	if len(history[15].History) != 1 {
+		t.Errorf("Expected 1 value for the last metric, got: %+v", history[15])
+	}
+}
@@ -1,5 +1,15 @@
 package sonarqube
 
+import "time"
+
+// DateTimeLayout is the layout of the date-time values returned by the SonarQube Web API
+const DateTimeLayout = "2006-01-02T15:04:05-0700"
+
+// ParseDateTime parses a date-time value returned by the SonarQube Web API
+func ParseDateTime(value string) (time.Time, error) {
+	return time.Parse(DateTimeLayout, value)
+}
+
 // Metric represents a SonarQube metric
 type Metric struct {
 	ID          string `json:"id"`
@@ -19,6 +29,16 @@ type MetricsResponse struct {
 	Total   int      `json:"total"`
 	P       int      `json:"p"`
 	PS      int      `json:"ps"`
+	Paging  *Paging  `json:"paging,omitempty"`
+}
+
+// total returns the number of metrics, reported at the top level of the response by current
+// SonarQube versions and in a paging object by others
+func (r MetricsResponse) total() int {
+	if r.Paging != nil {
+		return r.Paging.Total
+	}
+	return r.Total
 }
 
 // Component represents a SonarQube project
@@ -45,6 +65,21 @@ type ComponentsResponse struct {
 	Components []Component `json:"components"`
 }
 
+// ProjectsSearchResponse represents the response from /api/projects/search
+type ProjectsSearchResponse struct {
+	Paging     Paging           `json:"paging"`
+	Components []ProjectSummary `json:"components"`
+}
+
+// ProjectSummary represents a project listed by /api/projects/search
+type ProjectSummary struct {
+	Key              string `json:"key"`
+	Name             string `json:"name"`
+	Qualifier        string `json:"qualifier"`
+	Visibility       string `json:"visibility"`
+	LastAnalysisDate string `json:"lastAnalysisDate,omitempty"`
+}
+
 // MeasuresResponse represents the response from /api/measures/component
 type MeasuresResponse struct {
 	Component ComponentMeasures `json:"component"`
@@ -62,3 +97,234 @@ type Measure struct {
 	Metric string `json:"metric"`
 	Value  string `json:"value"`
 }
+
+// Analysis event categories
+const (
+	EventCategoryVersion        = "VERSION"
+	EventCategoryQualityGate    = "QUALITY_GATE"
+	EventCategoryQualityProfile = "QUALITY_PROFILE"
+)
+
+// MeasuresHistoryResponse represents the response from /api/measures/search_history
+type MeasuresHistoryResponse struct {
+	Paging   Paging           `json:"paging"`
+	Measures []MeasureHistory `json:"measures"`
+}
+
+// MeasureHistory represents the values of a metric at each analysis of a project
+type MeasureHistory struct {
+	Metric  string         `json:"metric"`
+	History []HistoryValue `json:"history"`
+}
+
+// HistoryValue represents the value of a metric at an analysis. The value is empty when the
+// metric had no value at that analysis.
+type HistoryValue struct {
+	Date  string `json:"date"`
+	Value string `json:"value,omitempty"`
+}
+
+// ProjectAnalysesResponse represents the response from /api/project_analyses/search
+type ProjectAnalysesResponse struct {
+	Paging   Paging     `json:"paging"`
+	Analyses []Analysis `json:"analyses"`
+}
+
+// Analysis represents a single analysis of a project
+type Analysis struct {
+	Key            string          `json:"key"`
+	Date           string          `json:"date"`
+	ProjectVersion string          `json:"projectVersion,omitempty"`
+	Revision       string          `json:"revision,omitempty"`
+	DetectedCI     string          `json:"detectedCI,omitempty"`
+	Events         []AnalysisEvent `json:"events"`
+}
+
+// AnalysisEvent represents an event attached to an analysis
+type AnalysisEvent struct {
+	Key         string `json:"key"`
+	Category    string `json:"category"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// HotspotsResponse represents the response from /api/hotspots/search
+type HotspotsResponse struct {
+	Paging   Paging    `json:"paging"`
+	Hotspots []Hotspot `json:"hotspots"`
+}
+
+// Hotspot represents a security hotspot
+type Hotspot struct {
+	Key                      string `json:"key"`
+	Component                string `json:"component"`
+	Project                  string `json:"project"`
+	SecurityCategory         string `json:"securityCategory"`
+	VulnerabilityProbability string `json:"vulnerabilityProbability"`
+	Status                   string `json:"status"`
+	Resolution               string `json:"resolution,omitempty"`
+}
+
+// Security standards supported by /api/security_reports/show
+const (
+	SecurityStandardOWASPTop10     = "owaspTop10"
+	SecurityStandardOWASPTop102021 = "owaspTop10-2021"
+	SecurityStandardSANSTop25      = "sansTop25"
+	SecurityStandardCWE            = "cwe"
+)
+
+// SecurityReport represents the response from /api/security_reports/show
+type SecurityReport struct {
+	Categories []SecurityReportCategory `json:"categories"`
+}
+
+// SecurityReportCategory represents the vulnerabilities and hotspots of a security standard category
+type SecurityReportCategory struct {
+	Category                 string `json:"category"`
+	Vulnerabilities          int    `json:"vulnerabilities"`
+	VulnerabilityRating      int    `json:"vulnerabilityRating,omitempty"`
+	ToReviewSecurityHotspots int    `json:"toReviewSecurityHotspots"`
+	ReviewedSecurityHotspots int    `json:"reviewedSecurityHotspots"`
+	SecurityReviewRating     int    `json:"securityReviewRating,omitempty"`
+}
+
+// QualityProfilesResponse represents the response from /api/qualityprofiles/search
+type QualityProfilesResponse struct {
+	Profiles []QualityProfile `json:"profiles"`
+}
+
+// QualityProfile represents a SonarQube quality profile
+type QualityProfile struct {
+	Key                       string `json:"key"`
+	Name                      string `json:"name"`
+	Language                  string `json:"language"`
+	LanguageName              string `json:"languageName"`
+	IsInherited               bool   `json:"isInherited"`
+	ParentKey                 string `json:"parentKey,omitempty"`
+	ParentName                string `json:"parentName,omitempty"`
+	IsDefault                 bool   `json:"isDefault"`
+	IsBuiltIn                 bool   `json:"isBuiltIn"`
+	ActiveRuleCount           int    `json:"activeRuleCount"`
+	ActiveDeprecatedRuleCount int    `json:"activeDeprecatedRuleCount"`
+	ProjectCount              int    `json:"projectCount,omitempty"`
+}
+
+// ProjectStatusResponse represents the response from /api/qualitygates/project_status
+type ProjectStatusResponse struct {
+	ProjectStatus ProjectStatus `json:"projectStatus"`
+}
+
+// ProjectStatus represents the quality gate status of a project
+type ProjectStatus struct {
+	Status     string                 `json:"status"`
+	Conditions []QualityGateCondition `json:"conditions"`
+}
+
+// QualityGateCondition represents the status of a quality gate condition
+type QualityGateCondition struct {
+	Status         string `json:"status"`
+	MetricKey      string `json:"metricKey"`
+	Comparator     string `json:"comparator"`
+	ErrorThreshold string `json:"errorThreshold,omitempty"`
+	ActualValue    string `json:"actualValue,omitempty"`
+}
+
+// ProjectBranchesResponse represents the response from /api/project_branches/list
+type ProjectBranchesResponse struct {
+	Branches []ProjectBranch `json:"branches"`
+}
+
+// ProjectBranch represents a branch of a project
+type ProjectBranch struct {
+	Name   string `json:"name"`
+	IsMain bool   `json:"isMain"`
+	Type   string `json:"type"`
+}
+
+// License represents the response from /api/editions/show_license
+type License struct {
+	Edition     string `json:"edition"`
+	Type        string `json:"type"`
+	ExpiresAt   string `json:"expiresAt,omitempty"`
+	MaxLoc      int64  `json:"maxLoc"`
+	Loc         int64  `json:"loc"`
+	IsExpired   bool   `json:"isExpired"`
+	IsSupported bool   `json:"isSupported"`
+}
+
+// LicenseUsageResponse represents the response from /api/projects/license_usage
+type LicenseUsageResponse struct {
+	Projects []ProjectLicenseUsage `json:"projects"`
+}
+
+// ProjectLicenseUsage represents the lines of code of a project counted against the license
+type ProjectLicenseUsage struct {
+	ProjectKey             string  `json:"projectKey"`
+	ProjectName            string  `json:"projectName"`
+	LinesOfCode            int64   `json:"linesOfCode"`
+	LicenseUsagePercentage float64 `json:"licenseUsagePercentage"`
+}
+
+// AuthenticationResponse represents the response from /api/authentication/validate
+type AuthenticationResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// UserTokensResponse represents the response from /api/user_tokens/search
+type UserTokensResponse struct {
+	Login      string      `json:"login"`
+	UserTokens []UserToken `json:"userTokens"`
+}
+
+// UserToken represents a token of the authenticated user
+type UserToken struct {
+	Name               string `json:"name"`
+	Type               string `json:"type,omitempty"`
+	CreatedAt          string `json:"createdAt"`
+	LastConnectionDate string `json:"lastConnectionDate,omitempty"`
+	ExpirationDate     string `json:"expirationDate,omitempty"`
+	IsExpired          bool   `json:"isExpired"`
+}
+
+// WebhookPayload represents the payload of the webhooks sent by SonarQube when an analysis completes
+type WebhookPayload struct {
+	ServerURL   string              `json:"serverUrl"`
+	TaskID      string              `json:"taskId"`
+	Status      string              `json:"status"`
+	AnalysedAt  string              `json:"analysedAt"`
+	Revision    string              `json:"revision"`
+	Project     WebhookProject      `json:"project"`
+	Branch      *WebhookBranch      `json:"branch,omitempty"`
+	QualityGate *WebhookQualityGate `json:"qualityGate,omitempty"`
+}
+
+// WebhookProject represents the project of a webhook payload
+type WebhookProject struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// WebhookBranch represents the branch or pull request of a webhook payload
+type WebhookBranch struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	IsMain bool   `json:"isMain"`
+	URL    string `json:"url"`
+}
+
+// WebhookQualityGate represents the quality gate of a webhook payload
+type WebhookQualityGate struct {
+	Name       string                        `json:"name"`
+	Status     string                        `json:"status"`
+	Conditions []WebhookQualityGateCondition `json:"conditions"`
+}
+
+// WebhookQualityGateCondition represents a condition of the quality gate of a webhook payload
+type WebhookQualityGateCondition struct {
+	Metric         string `json:"metric"`
+	Operator       string `json:"operator"`
+	Value          string `json:"value,omitempty"`
+	Status         string `json:"status"`
+	ErrorThreshold string `json:"errorThreshold"`
+}
@@ -0,0 +1,38 @@
+package sonarqube
+
+import (
+	"errors"
+	"fmt"
+)
+
+// searchWindow is the number of results Elasticsearch pages through, beyond which the search
+// endpoints, such as /api/components/search_projects and /api/hotspots/search, refuse requests
+const searchWindow = 10000
+
+// errPageLimit is returned by paginate when an endpoint has more pages than may be requested
+var errPageLimit = errors.New("too many pages")
+
+// pageFunc requests a page of a paginated endpoint, and returns the number of results it held and
+// the total number of results reported by SonarQube
+type pageFunc func(pageIndex int) (results, total int, err error)
+
+// paginate requests the pages of an endpoint in order, until the reported total of results is
+// reached. Should it never be, paginate stops on an empty page rather than looping forever, and
+// fails with errPageLimit once maxPages pages were requested.
+func paginate(endpoint string, maxPages int, fetch pageFunc) error {
+	fetched := 0
+
+	for pageIndex := 1; pageIndex <= maxPages; pageIndex++ {
+		results, total, err := fetch(pageIndex)
+		if err != nil {
+			return err
+		}
+
+		fetched += results
+		if results == 0 || fetched >= total {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s returned more than %d pages", errPageLimit, endpoint, maxPages)
+}
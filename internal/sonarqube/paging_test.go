@@ -0,0 +1,70 @@
+package sonarqube
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name          string
+		results       func(pageIndex int) int
+		total         int
+		expectedPages int
+		expectedErr   error
+	}{
+		{
+			name:          "stops at the total",
+			results:       func(pageIndex int) int { return 10 },
+			total:         25,
+			expectedPages: 3,
+		},
+		{
+			name:          "stops on an empty page",
+			results:       func(pageIndex int) int { return 10 - 10*(pageIndex/2) },
+			total:         100,
+			expectedPages: 2,
+		},
+		{
+			name:          "fails beyond the page limit",
+			results:       func(pageIndex int) int { return 10 },
+			total:         1000,
+			expectedPages: 5,
+			expectedErr:   errPageLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := 0
+			err := paginate("/api/test", 5, func(pageIndex int) (int, int, error) {
+				pages++
+				if pageIndex != pages {
+					t.Errorf("Expected page %d, got: %d", pages, pageIndex)
+				}
+				return tt.results(pageIndex), tt.total, nil
+			})
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got: %v", tt.expectedErr, err)
+			}
+			if pages != tt.expectedPages {
+				t.Errorf("Expected %d pages, got: %d", tt.expectedPages, pages)
+			}
+		})
+	}
+}
+
+func TestPaginate_Error(t *testing.T) {
+	failure := errors.New("boom")
+	pages := 0
+
+	err := paginate("/api/test", 5, func(pageIndex int) (int, int, error) {
+		pages++
+		return 0, 0, failure
+	})
+
+	if !errors.Is(err, failure) || pages != 1 {
+		t.Errorf("Expected to stop on the first error, got %v after %d pages", err, pages)
+	}
+}
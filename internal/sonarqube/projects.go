@@ -0,0 +1,131 @@
+package sonarqube
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// projectsPageSize is the maximum page size of the project endpoints
+	projectsPageSize = 500
+	// maxProjectPages is the page limit of /api/projects/search
+	maxProjectPages = 1000
+)
+
+// ErrProjectListTruncated is returned with the projects listed so far when the instance has more
+// projects than the search index pages through, and the remaining ones could not be listed
+var ErrProjectListTruncated = errors.New("project list truncated")
+
+// GetProjects retrieves all projects from SonarQube.
+//
+// Projects are searched through /api/components/search_projects, which also returns their tags,
+// but only pages through the first 10,000 results. On larger instances, the remaining projects are
+// listed from /api/projects/search, which reads the database and requires the Administer System
+// permission. If it fails, the projects found so far are returned with ErrProjectListTruncated.
+func (c *Client) GetProjects() ([]Component, error) {
+	projects, total, err := c.searchProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	if total <= searchWindow {
+		return projects, nil
+	}
+
+	listed, err := c.listProjects()
+	if err != nil {
+		return projects, fmt.Errorf("%w: %d of %d projects listed: %w", ErrProjectListTruncated, len(projects), total, err)
+	}
+
+	return mergeProjects(projects, listed), nil
+}
+
+// searchProjects pages through /api/components/search_projects, up to the search window, and
+// returns the projects found with the total reported by SonarQube
+func (c *Client) searchProjects() ([]Component, int, error) {
+	var allComponents []Component
+	seen := make(map[string]bool)
+	total := 0
+
+	err := paginate("/api/components/search_projects", searchWindow/projectsPageSize, func(pageIndex int) (int, int, error) {
+		params := url.Values{}
+		params.Set("ps", strconv.Itoa(projectsPageSize))
+		params.Set("p", strconv.Itoa(pageIndex))
+
+		var componentsResp ComponentsResponse
+		if err := c.get("/api/components/search_projects", params, &componentsResp); err != nil {
+			return 0, 0, err
+		}
+		total = componentsResp.Paging.Total
+
+		// Projects created or deleted while paging shift the results, so keys are deduplicated
+		for _, component := range componentsResp.Components {
+			if !seen[component.Key] {
+				seen[component.Key] = true
+				allComponents = append(allComponents, component)
+			}
+		}
+		return len(componentsResp.Components), total, nil
+	})
+
+	// Beyond the search window, GetProjects lists the remaining projects
+	if err != nil && !errors.Is(err, errPageLimit) {
+		return nil, 0, err
+	}
+
+	return allComponents, total, nil
+}
+
+// listProjects pages through /api/projects/search, which is not limited by the search window
+func (c *Client) listProjects() ([]Component, error) {
+	var allComponents []Component
+
+	err := paginate("/api/projects/search", maxProjectPages, func(pageIndex int) (int, int, error) {
+		params := url.Values{}
+		params.Set("qualifiers", "TRK")
+		params.Set("ps", strconv.Itoa(projectsPageSize))
+		params.Set("p", strconv.Itoa(pageIndex))
+
+		var projectsResp ProjectsSearchResponse
+		if err := c.get("/api/projects/search", params, &projectsResp); err != nil {
+			return 0, 0, err
+		}
+
+		for _, project := range projectsResp.Components {
+			allComponents = append(allComponents, Component{
+				Key:          project.Key,
+				Name:         project.Name,
+				Qualifier:    project.Qualifier,
+				AnalysisDate: project.LastAnalysisDate,
+				Visibility:   project.Visibility,
+			})
+		}
+		return len(projectsResp.Components), projectsResp.Paging.Total, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allComponents, nil
+}
+
+// mergeProjects appends the listed projects missing from the searched ones, which are kept first
+// as they carry the project tags
+func mergeProjects(searched, listed []Component) []Component {
+	seen := make(map[string]bool, len(searched))
+	for _, project := range searched {
+		seen[project.Key] = true
+	}
+
+	merged := searched
+	for _, project := range listed {
+		if !seen[project.Key] {
+			seen[project.Key] = true
+			merged = append(merged, project)
+		}
+	}
+
+	return merged
+}
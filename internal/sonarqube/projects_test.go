@@ -0,0 +1,148 @@
+package sonarqube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newLargeInstanceServer creates a mock SonarQube server with more projects than the search window.
+// The search endpoint enforces the window, like Elasticsearch, and the listing endpoint answers
+// with the given status code when it is not 200.
+func newLargeInstanceServer(t *testing.T, total int, listStatus int) *httptest.Server {
+	t.Helper()
+
+	projectKey := func(i int) string { return fmt.Sprintf("project%05d", i) }
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("ps"))
+		start, end := (page-1)*size, min(page*size, total)
+
+		switch r.URL.Path {
+		case "/api/components/search_projects":
+			if page*size > searchWindow {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":[{"msg":"Can return only the first 10000 results"}]}`))
+				return
+			}
+
+			response := ComponentsResponse{Paging: Paging{PageIndex: page, PageSize: size, Total: total}}
+			for i := start; i < end; i++ {
+				response.Components = append(response.Components, Component{Key: projectKey(i), Tags: []string{"searched"}})
+			}
+			json.NewEncoder(w).Encode(response)
+
+		case "/api/projects/search":
+			if listStatus != http.StatusOK {
+				w.WriteHeader(listStatus)
+				return
+			}
+
+			response := ProjectsSearchResponse{Paging: Paging{PageIndex: page, PageSize: size, Total: total}}
+			for i := start; i < end; i++ {
+				response.Components = append(response.Components, ProjectSummary{Key: projectKey(i), LastAnalysisDate: "2024-01-15T10:30:00+0000"})
+			}
+			json.NewEncoder(w).Encode(response)
+		}
+	}))
+}
+
+func TestGetProjects_BeyondSearchWindow(t *testing.T) {
+	server := newLargeInstanceServer(t, 10600, http.StatusOK)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	projects, err := client.GetProjects()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(projects) != 10600 {
+		t.Fatalf("Expected 10600 projects, got: %d", len(projects))
+	}
+
+	// Searched projects keep their tags, and listed ones fill the rest
+	if len(projects[0].Tags) != 1 {
+		t.Errorf("Expected searched project to keep its tags, got: %v", projects[0].Tags)
+	}
+
+	last := projects[len(projects)-1]
+	if last.Key != "project10599" || last.AnalysisDate != "2024-01-15T10:30:00+0000" {
+		t.Errorf("Expected listed project 'project10599' with its analysis date, got: %+v", last)
+	}
+}
+
+func TestGetProjects_Truncated(t *testing.T) {
+	server := newLargeInstanceServer(t, 10600, http.StatusForbidden)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	projects, err := client.GetProjects()
+
+	if !errors.Is(err, ErrProjectListTruncated) {
+		t.Fatalf("Expected ErrProjectListTruncated, got: %v", err)
+	}
+
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected the cause to be kept, got: %v", err)
+	}
+
+	if len(projects) != searchWindow {
+		t.Errorf("Expected the %d searched projects, got: %d", searchWindow, len(projects))
+	}
+}
+
+func TestGetProjects_EmptyPage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		// The total is stale: projects were deleted since the first page
+		response := ComponentsResponse{Paging: Paging{Total: 800}}
+		if r.URL.Query().Get("p") == "1" {
+			response.Components = []Component{{Key: "project1"}, {Key: "project2"}}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	projects, err := client.GetProjects()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(projects) != 2 || requests != 2 {
+		t.Errorf("Expected 2 projects in 2 requests, got: %d projects in %d requests", len(projects), requests)
+	}
+}
+
+func TestListProjects_PageGuard(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		// A broken paging that is never reached
+		json.NewEncoder(w).Encode(ProjectsSearchResponse{
+			Paging:     Paging{Total: 1 << 30},
+			Components: []ProjectSummary{{Key: "project" + r.URL.Query().Get("p")}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	if _, err := client.listProjects(); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if requests != maxProjectPages {
+		t.Errorf("Expected %d requests, got: %d", maxProjectPages, requests)
+	}
+}
@@ -0,0 +1,243 @@
+package sonarqube
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSOptions holds the TLS settings of the connections to SonarQube
+type TLSOptions struct {
+	// URL is the SonarQube URL, whose host the server certificate is verified against when
+	// ServerName is empty
+	URL string
+	// CAFile is a PEM bundle of the certificate authorities trusted instead of the system ones
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented to mTLS gateways
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version, the Go default when zero
+	MinVersion uint16
+	// ServerName overrides the server name used for SNI and certificate verification
+	ServerName string
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+}
+
+// tlsVersions maps the accepted minimum TLS versions to their constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version such as 1.2, returning zero for an empty value
+func ParseTLSVersion(value string) (uint16, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "TLS")
+	if value == "" {
+		return 0, nil
+	}
+
+	version, ok := tlsVersions[value]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", value)
+	}
+
+	return version, nil
+}
+
+// NewTLSConfig builds the TLS configuration of the connections to SonarQube. The CA bundle and
+// the client certificate are loaded once to fail fast on invalid files, then reloaded on the next
+// handshake whenever their files change on disk, so that certificate rotations need no restart.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("the client certificate and key files must be set together")
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         opts.MinVersion,
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	files := &tlsFiles{caFile: opts.CAFile, certFile: opts.CertFile, keyFile: opts.KeyFile}
+
+	if opts.CAFile != "" && !opts.InsecureSkipVerify {
+		if _, err := files.rootCAs(); err != nil {
+			return nil, err
+		}
+
+		// The name is captured here, as the connection state has no server name when none is sent
+		// through SNI, such as for IP addresses
+		files.serverName = opts.ServerName
+		if files.serverName == "" {
+			if u, err := url.Parse(opts.URL); err == nil {
+				files.serverName = u.Hostname()
+			}
+		}
+		if files.serverName == "" {
+			return nil, errors.New("no server name to verify the SonarQube certificate against, set the server name")
+		}
+
+		// The standard verification only knows the pool set at creation, so it is disabled and
+		// performed in VerifyConnection against the latest CA bundle instead
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = files.verifyConnection
+	}
+
+	if opts.CertFile != "" {
+		if _, err := files.clientCertificate(nil); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = files.clientCertificate
+	}
+
+	return cfg, nil
+}
+
+// WithTLSConfig sets the TLS configuration of the connections to SonarQube
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.transport.TLSClientConfig = cfg
+	}
+}
+
+// tlsFiles caches the CA bundle and the client certificate, reloading them when their files change.
+// serverName is the name the server certificate is verified against.
+type tlsFiles struct {
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+
+	mu          sync.Mutex
+	pool        *x509.CertPool
+	caModTime   time.Time
+	cert        *tls.Certificate
+	certModTime time.Time
+}
+
+// rootCAs returns the pool of the CA bundle, reloaded if the file changed. The previous pool is
+// kept when the new file cannot be loaded, e.g. while it is being written.
+func (f *tlsFiles) rootCAs() (*x509.CertPool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTime, err := latestModTime(f.caFile)
+	if err == nil && f.pool != nil && modTime.Equal(f.caModTime) {
+		return f.pool, nil
+	}
+
+	if err == nil {
+		var pool *x509.CertPool
+		if pool, err = loadCertPool(f.caFile); err == nil {
+			if f.pool != nil {
+				log.Printf("SonarQube CA bundle reloaded from %s", f.caFile)
+			}
+			f.pool, f.caModTime = pool, modTime
+			return f.pool, nil
+		}
+	}
+
+	if f.pool == nil {
+		return nil, err
+	}
+	log.Printf("Error reloading SonarQube CA bundle, keeping the current one: %v", err)
+	f.caModTime = modTime
+	return f.pool, nil
+}
+
+// clientCertificate returns the client certificate, reloaded if its files changed. It has the
+// signature of tls.Config.GetClientCertificate.
+func (f *tlsFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTime, err := latestModTime(f.certFile, f.keyFile)
+	if err == nil && f.cert != nil && modTime.Equal(f.certModTime) {
+		return f.cert, nil
+	}
+
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(f.certFile, f.keyFile); err == nil {
+			if f.cert != nil {
+				log.Printf("SonarQube client certificate reloaded from %s", f.certFile)
+			}
+			f.cert, f.certModTime = &cert, modTime
+			return f.cert, nil
+		}
+		err = fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	if f.cert == nil {
+		return nil, err
+	}
+	log.Printf("Error reloading SonarQube client certificate, keeping the current one: %v", err)
+	f.certModTime = modTime
+	return f.cert, nil
+}
+
+// verifyConnection verifies the server certificate chain and name against the CA bundle. It has
+// the signature of tls.Config.VerifyConnection.
+func (f *tlsFiles) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	pool, err := f.rootCAs()
+	if err != nil {
+		return err
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       f.serverName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err = cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// loadCertPool loads a PEM bundle of certificate authorities
+func loadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("CA file %s contains no PEM certificate", path)
+	}
+
+	return pool, nil
+}
+
+// latestModTime returns the latest modification time of the files. Stating the files through their
+// path also follows the symlinks swapped by Kubernetes secret rotations.
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,311 @@
+package sonarqube
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing the certificates of the TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a certificate signed by the CA and returns its certificate and key PEM
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage, dnsNames ...string) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newMTLSServer creates a mock SonarQube server for sonar.internal that requires a client
+// certificate issued by the CA, and records the common name of the last client
+func newMTLSServer(t *testing.T, ca *testCA) (*httptest.Server, func() string) {
+	t.Helper()
+
+	certPEM, keyPEM := ca.issue(t, "sonar.internal", x509.ExtKeyUsageServerAuth, "sonar.internal")
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load server certificate: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	var mu sync.Mutex
+	var lastClient string
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastClient = r.TLS.PeerCertificates[0].Subject.CommonName
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthenticationResponse{Valid: true})
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return lastClient
+	}
+}
+
+// writeFile writes a test file and moves its modification time forward, so that successive
+// writes are detected even on file systems with a coarse time resolution
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time of %s: %v", path, err)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		value     string
+		expected  uint16
+		shouldErr bool
+	}{
+		{value: "", expected: 0},
+		{value: "1.2", expected: tls.VersionTLS12},
+		{value: "TLS1.3", expected: tls.VersionTLS13},
+		{value: "1.4", shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		version, err := ParseTLSVersion(tt.value)
+		if tt.shouldErr {
+			if err == nil {
+				t.Errorf("Expected error for %q, got nil", tt.value)
+			}
+			continue
+		}
+		if err != nil || version != tt.expected {
+			t.Errorf("Expected %q to parse to %d, got: %d (%v)", tt.value, tt.expected, version, err)
+		}
+	}
+}
+
+func TestNewTLSConfig_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	writeFile(t, invalid, []byte("not a certificate"), time.Now())
+	ca := filepath.Join(dir, "ca.pem")
+	writeFile(t, ca, newTestCA(t).pem, time.Now())
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "certificate without key", opts: TLSOptions{CertFile: invalid}},
+		{name: "invalid CA file", opts: TLSOptions{CAFile: invalid}},
+		{name: "missing CA file", opts: TLSOptions{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "CA file without server name", opts: TLSOptions{CAFile: ca}},
+		{name: "invalid key pair", opts: TLSOptions{CertFile: invalid, KeyFile: invalid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(tt.opts); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_MTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server, lastClient := newMTLSServer(t, ca)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	modTime := time.Now().Add(-time.Minute)
+	certPEM, keyPEM := ca.issue(t, "exporter-1", x509.ExtKeyUsageClientAuth)
+	writeFile(t, caFile, ca.pem, modTime)
+	writeFile(t, certFile, certPEM, modTime)
+	writeFile(t, keyFile, keyPEM, modTime)
+
+	tlsConfig, err := NewTLSConfig(TLSOptions{
+		CAFile:     caFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		MinVersion: tls.VersionTLS12,
+		ServerName: "sonar.internal",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	client := NewClient(server.URL, "test-token", WithTLSConfig(tlsConfig))
+	if _, err := client.ValidateAuthentication(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if name := lastClient(); name != "exporter-1" {
+		t.Errorf("Expected client certificate 'exporter-1', got: '%s'", name)
+	}
+
+	// Rotate the client certificate and break the CA bundle: the new certificate is picked up
+	// on the next handshake while the previous CA bundle is kept
+	certPEM, keyPEM = ca.issue(t, "exporter-2", x509.ExtKeyUsageClientAuth)
+	modTime = modTime.Add(time.Second)
+	writeFile(t, certFile, certPEM, modTime)
+	writeFile(t, keyFile, keyPEM, modTime)
+	writeFile(t, caFile, []byte("being rewritten"), modTime)
+	client.transport.CloseIdleConnections()
+
+	if _, err := client.ValidateAuthentication(); err != nil {
+		t.Fatalf("Expected no error after rotation, got: %v", err)
+	}
+	if name := lastClient(); name != "exporter-2" {
+		t.Errorf("Expected client certificate 'exporter-2' after rotation, got: '%s'", name)
+	}
+}
+
+func TestNewTLSConfig_UntrustedServer(t *testing.T) {
+	ca := newTestCA(t)
+	server, _ := newMTLSServer(t, ca)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	certPEM, keyPEM := ca.issue(t, "exporter-1", x509.ExtKeyUsageClientAuth)
+	writeFile(t, caFile, newTestCA(t).pem, time.Now())
+	writeFile(t, certFile, certPEM, time.Now())
+	writeFile(t, keyFile, keyPEM, time.Now())
+
+	tlsConfig, err := NewTLSConfig(TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "sonar.internal"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	client := NewClient(server.URL, "test-token", WithTLSConfig(tlsConfig))
+	if _, err := client.ValidateAuthentication(); err == nil {
+		t.Error("Expected error for a server certificate issued by another CA, got nil")
+	}
+}
+
+func TestNewTLSConfig_ServerNameMismatch(t *testing.T) {
+	ca := newTestCA(t)
+	server, _ := newMTLSServer(t, ca)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	certPEM, keyPEM := ca.issue(t, "exporter-1", x509.ExtKeyUsageClientAuth)
+	writeFile(t, caFile, ca.pem, time.Now())
+	writeFile(t, certFile, certPEM, time.Now())
+	writeFile(t, keyFile, keyPEM, time.Now())
+
+	// The certificate is only issued for sonar.internal, and is served at 127.0.0.1, for which
+	// no name is sent through SNI
+	tests := []struct {
+		name       string
+		serverName string
+	}{
+		{name: "IP address of the URL", serverName: ""},
+		{name: "other server name", serverName: "other.internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := NewTLSConfig(TLSOptions{
+				URL:        server.URL,
+				CAFile:     caFile,
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				ServerName: tt.serverName,
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			client := NewClient(server.URL, "test-token", WithTLSConfig(tlsConfig))
+			if _, err := client.ValidateAuthentication(); err == nil {
+				t.Error("Expected error for a certificate not issued for the server name, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package sonarqube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ReadTokenFile reads a token from a file, ignoring surrounding whitespace
+func ReadTokenFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+
+	return token, nil
+}
+
+// WatchTokenFile polls the token file at the given interval and updates the token of the client
+// whenever the file content changes, until the context is done. Reading the file through its path
+// on every poll also picks up Kubernetes secrets, which are rotated by swapping symlinks.
+func (c *Client) WatchTokenFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			token, err := ReadTokenFile(path)
+			if err != nil {
+				log.Printf("Error reloading SonarQube token, keeping the current one: %v", err)
+				continue
+			}
+
+			if token != c.currentToken() {
+				c.SetToken(token)
+				log.Printf("SonarQube token reloaded from %s", path)
+			}
+		}
+	}
+}
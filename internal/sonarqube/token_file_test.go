@@ -0,0 +1,63 @@
+package sonarqube
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadTokenFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("  file-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	token, err := ReadTokenFile(tokenFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token != "file-token" {
+		t.Errorf("Expected token 'file-token', got: '%s'", token)
+	}
+
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	if _, err := ReadTokenFile(emptyFile); err == nil {
+		t.Error("Expected error for an empty token file, got nil")
+	}
+
+	if _, err := ReadTokenFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for a missing token file, got nil")
+	}
+}
+
+func TestWatchTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old-token"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	client := NewClient("https://sonar.example.com", "old-token")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.WatchTokenFile(ctx, tokenFile, 10*time.Millisecond)
+
+	if err := os.WriteFile(tokenFile, []byte("new-token"), 0o600); err != nil {
+		t.Fatalf("Failed to rotate token file: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for client.currentToken() != "new-token" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected token to be reloaded, got: %s", client.currentToken())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
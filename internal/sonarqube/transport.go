@@ -0,0 +1,119 @@
+package sonarqube
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TransportOptions holds the connection settings of the Client. Zero values keep the defaults
+// of the Go HTTP client.
+type TransportOptions struct {
+	// ProxyURL is the proxy used for SonarQube requests, instead of the HTTP_PROXY and HTTPS_PROXY
+	// environment variables
+	ProxyURL *url.URL
+	// NoProxy lists the hosts, domains (matching their subdomains) and CIDR ranges reached
+	// without ProxyURL, "*" matching all of them
+	NoProxy []string
+
+	// MaxIdleConns and MaxIdleConnsPerHost size the pool of idle connections kept for reuse
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept in the pool
+	IdleConnTimeout time.Duration
+	// KeepAlive is the period of TCP keep-alive probes, negative to disable them
+	KeepAlive time.Duration
+
+	// Timeout bounds a whole request, including reading the response body
+	Timeout time.Duration
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout bound each step of a request
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// DisableHTTP2 restricts the connections to HTTP/1.1
+	DisableHTTP2 bool
+}
+
+// WithTransportOptions sets the connection settings of the Client
+func WithTransportOptions(opts TransportOptions) ClientOption {
+	return func(c *Client) {
+		if opts.ProxyURL != nil {
+			c.transport.Proxy = proxyFunc(opts.ProxyURL, opts.NoProxy)
+		}
+
+		if opts.MaxIdleConns != 0 {
+			c.transport.MaxIdleConns = opts.MaxIdleConns
+		}
+		if opts.MaxIdleConnsPerHost != 0 {
+			c.transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		}
+		if opts.IdleConnTimeout != 0 {
+			c.transport.IdleConnTimeout = opts.IdleConnTimeout
+		}
+
+		if opts.KeepAlive != 0 {
+			c.dialer.KeepAlive = opts.KeepAlive
+		}
+		if opts.DialTimeout != 0 {
+			c.dialer.Timeout = opts.DialTimeout
+		}
+		if opts.TLSHandshakeTimeout != 0 {
+			c.transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+		}
+		if opts.ResponseHeaderTimeout != 0 {
+			c.transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+		}
+		if opts.Timeout != 0 {
+			c.httpClient.Timeout = opts.Timeout
+		}
+
+		if opts.DisableHTTP2 {
+			protocols := new(http.Protocols)
+			protocols.SetHTTP1(true)
+			c.transport.Protocols = protocols
+		}
+	}
+}
+
+// proxyFunc returns a proxy selection function sending requests through the proxy, except for
+// the hosts matching the no-proxy list
+func proxyFunc(proxyURL *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if matchesNoProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// matchesNoProxy reports whether a host matches an entry of the no-proxy list
+func matchesNoProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "*":
+			return true
+		case ip != nil:
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+			if ip.Equal(net.ParseIP(entry)) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package sonarqube
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMatchesNoProxy(t *testing.T) {
+	noProxy := []string{"localhost", ".corp.example.com", "10.0.0.0/8", "192.168.1.10"}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "localhost", expected: true},
+		{host: "sonar.corp.example.com", expected: true},
+		{host: "corp.example.com", expected: true},
+		{host: "notcorp.example.com", expected: false},
+		{host: "10.20.30.40", expected: true},
+		{host: "192.168.1.10", expected: true},
+		{host: "192.168.1.11", expected: false},
+		{host: "sonarcloud.io", expected: false},
+	}
+
+	for _, tt := range tests {
+		if result := matchesNoProxy(tt.host, noProxy); result != tt.expected {
+			t.Errorf("Expected %s to match the no-proxy list: %v, got: %v", tt.host, tt.expected, result)
+		}
+	}
+
+	if !matchesNoProxy("sonarcloud.io", []string{"*"}) {
+		t.Error("Expected * to match every host")
+	}
+}
+
+// newValidHandler returns a handler answering a valid authentication, and records the host requested
+func newValidHandler(host *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*host = r.Host
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthenticationResponse{Valid: true})
+	}
+}
+
+func TestWithTransportOptions_Proxy(t *testing.T) {
+	var proxiedHost, directHost string
+	proxy := httptest.NewServer(newValidHandler(&proxiedHost))
+	defer proxy.Close()
+	server := httptest.NewServer(newValidHandler(&directHost))
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	// Requests to SonarQube go through the proxy, which answers them in place of the unreachable host
+	client := NewClient("http://sonar.example.com", "test-token", WithTransportOptions(TransportOptions{ProxyURL: proxyURL}))
+	if _, err := client.ValidateAuthentication(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if proxiedHost != "sonar.example.com" {
+		t.Errorf("Expected proxied request for 'sonar.example.com', got: '%s'", proxiedHost)
+	}
+
+	// Hosts of the no-proxy list are reached directly
+	client = NewClient(server.URL, "test-token", WithTransportOptions(TransportOptions{ProxyURL: proxyURL, NoProxy: []string{"127.0.0.1"}}))
+	if _, err := client.ValidateAuthentication(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if directHost == "" {
+		t.Error("Expected the request to reach the server directly")
+	}
+}
+
+func TestWithTransportOptions_DisableHTTP2(t *testing.T) {
+	var proto int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.ProtoMajor
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthenticationResponse{Valid: true})
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	tlsConfig := &tls.Config{RootCAs: roots}
+
+	tests := []struct {
+		name     string
+		disable  bool
+		expected int
+	}{
+		{name: "HTTP/2 enabled", disable: false, expected: 2},
+		{name: "HTTP/2 disabled", disable: true, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(server.URL, "test-token",
+				WithTLSConfig(tlsConfig.Clone()),
+				WithTransportOptions(TransportOptions{DisableHTTP2: tt.disable}),
+			)
+			if _, err := client.ValidateAuthentication(); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if proto != tt.expected {
+				t.Errorf("Expected HTTP/%d, got: HTTP/%d", tt.expected, proto)
+			}
+		})
+	}
+}
+
+func TestWithTransportOptions_ResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", WithTransportOptions(TransportOptions{
+		ResponseHeaderTimeout: 20 * time.Millisecond,
+		MaxIdleConnsPerHost:   10,
+	}))
+
+	if _, err := client.ValidateAuthentication(); err == nil {
+		t.Fatal("Expected timeout error, got nil")
+	}
+	if client.transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("Expected 10 idle connections per host, got: %d", client.transport.MaxIdleConnsPerHost)
+	}
+	if client.httpClient.Timeout != 30*time.Second {
+		t.Errorf("Expected the default timeout to be kept, got: %v", client.httpClient.Timeout)
+	}
+}
@@ -0,0 +1,29 @@
+package sonarqube
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// WebhookSignatureHeader is the header carrying the signature of the webhooks sent by SonarQube
+const WebhookSignatureHeader = "X-Sonar-Webhook-HMAC-SHA256"
+
+// VerifyWebhookSignature reports whether the signature is the hex-encoded HMAC-SHA256 of the
+// webhook body with the secret configured on SonarQube
+func VerifyWebhookSignature(body []byte, secret, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// IsMainBranch reports whether the webhook is about the main branch of the project. Webhooks of
+// SonarQube versions without branch support have no branch.
+func (p WebhookPayload) IsMainBranch() bool {
+	return p.Branch == nil || p.Branch.IsMain
+}
@@ -0,0 +1,47 @@
+package sonarqube
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"project":{"key":"project1"}}`)
+
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		body      []byte
+		secret    string
+		signature string
+		expected  bool
+	}{
+		{name: "valid", body: body, secret: "webhook-secret", signature: signature, expected: true},
+		{name: "wrong secret", body: body, secret: "other-secret", signature: signature, expected: false},
+		{name: "tampered body", body: []byte(`{"project":{"key":"project2"}}`), secret: "webhook-secret", signature: signature, expected: false},
+		{name: "missing signature", body: body, secret: "webhook-secret", signature: "", expected: false},
+		{name: "not hex", body: body, secret: "webhook-secret", signature: "not-hex", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := VerifyWebhookSignature(tt.body, tt.secret, tt.signature); result != tt.expected {
+				t.Errorf("Expected %v, got: %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWebhookPayload_IsMainBranch(t *testing.T) {
+	if !(WebhookPayload{}).IsMainBranch() {
+		t.Error("Expected payload without branch to be about the main branch")
+	}
+	if (WebhookPayload{Branch: &WebhookBranch{Name: "feature", Type: "BRANCH"}}).IsMainBranch() {
+		t.Error("Expected payload of a feature branch not to be about the main branch")
+	}
+}